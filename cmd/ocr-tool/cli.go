@@ -1,16 +1,56 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"ocr-tool/internal/data"
+	"ocr-tool/internal/image"
+	"ocr-tool/internal/ocr"
 	"ocr-tool/internal/pipeline"
+	"ocr-tool/internal/writer"
+	"strings"
 )
 
+// tesseractFlags holds the raw Tesseract flags until they are merged with
+// the config file.
+type tesseractFlags struct {
+	languages  string
+	tessdata   string
+	psm        int
+	oem        int
+	whitelist  string
+	blacklist  string
+	configFile string
+	variables  []string
+}
+
+// ollamaFlags holds the raw Ollama flags until they are merged with the
+// environment and the config file.
+type ollamaFlags struct {
+	baseURL     string
+	model       string
+	keepAlive   string
+	temperature float64
+	seed        int
+	numCtx      int
+	numPredict  int
+}
+
 type CLI struct {
-	imagesDir  string
-	outputDir  string
-	engineType string
-	outputFile string
+	imagesDir   string
+	outputDir   string
+	engineType  string
+	outputFiles []string
+	format      string
+	configPath  string
+	preprocess  string
+	variants    []string
+	quality     image.QualityThresholds
+	tesseract   tesseractFlags
+	ollama      ollamaFlags
+	options     pipeline.Options
 }
 
 func NewCLI() *CLI {
@@ -18,35 +58,222 @@ func NewCLI() *CLI {
 		imagesDir:  "images",
 		outputDir:  "output",
 		engineType: "gosseract",
+		format:     string(writer.FormatCSV),
 	}
 }
 
-func (c *CLI) Run(args []string) error {
+// errInterrupted is returned when the run was cancelled by a signal.
+var errInterrupted = errors.New("interrupted")
+
+func (c *CLI) Run(ctx context.Context, args []string) error {
 	fs := flag.NewFlagSet("ocr-tool", flag.ExitOnError)
 
 	fs.StringVar(&c.imagesDir, "images", c.imagesDir, "Directory containing images to process")
 	fs.StringVar(&c.outputDir, "output", c.outputDir, "Output directory for results")
 	fs.StringVar(&c.engineType, "engine", c.engineType, "OCR engine type (ollama, gosseract)")
+	fs.StringVar(&c.format, "format", c.format, "Comma-separated output formats (csv, json, ndjson)")
+	fs.IntVar(&c.options.OCRWorkers, "ocr-workers", c.options.OCRWorkers, "Number of OCR workers (0 = derived from CPU count and engine)")
+	fs.IntVar(&c.options.EnhanceWorkers, "enhance-workers", c.options.EnhanceWorkers, "Number of image enhancement workers (0 = derived from CPU count and engine)")
+	fs.DurationVar(&c.options.OCRTimeout, "ocr-timeout", c.options.OCRTimeout, "Longest OCR of one image before it fails as timed out, such as 90s (0 = no limit)")
+	fs.IntVar(&c.options.Engine.Retry.MaxAttempts, "ocr-attempts", c.options.Engine.Retry.MaxAttempts, "OCR calls per image when the engine fails transiently: connection refused, 429, 5xx, unparseable answer (0 = 4, 1 = no retry)")
+	fs.DurationVar(&c.options.Engine.Retry.BaseDelay, "retry-delay", c.options.Engine.Retry.BaseDelay, "Wait before the first retry, doubled for each next one with jitter (0 = 1s)")
+	fs.DurationVar(&c.options.Engine.Retry.MaxDelay, "retry-max-delay", c.options.Engine.Retry.MaxDelay, "Longest wait between retries (0 = 30s)")
+	fs.IntVar(&c.options.MaxInFlight, "max-inflight", c.options.MaxInFlight, "Maximum enhanced images waiting for OCR (0 = twice the OCR workers)")
+	fs.BoolVar(&c.options.Recursive, "recursive", c.options.Recursive, "Walk subdirectories of the images directory")
+	fs.IntVar(&c.options.MaxDepth, "max-depth", c.options.MaxDepth, "Deepest subdirectory level to walk with --recursive (0 = unlimited)")
+	fs.Var((*stringList)(&c.options.Include), "include", "Glob of files to process, repeatable (default "+strings.Join(pipeline.DefaultInclude, ", ")+")")
+	fs.Var((*stringList)(&c.options.Exclude), "exclude", "Glob of files to ignore, repeatable")
+	fs.BoolVar(&c.options.SkipHidden, "skip-hidden", c.options.SkipHidden, "Ignore files and directories whose name starts with a dot, archive entries included")
+	fs.BoolVar(&c.options.Resume, "resume", c.options.Resume, "Resume a previous run, skipping inputs its manifest records as done")
+	fs.StringVar(&c.configPath, "config", c.configPath, "JSON run configuration file (YAML is not supported), overridden by flags")
+	fs.StringVar(&c.options.DebugDir, "debug-images", c.options.DebugDir, "Directory to save the output of every preprocessing step per input, with a steps.json sidecar")
+	fs.StringVar(&c.options.LayoutDir, "layout-dir", c.options.LayoutDir, "Directory to save the word layout JSON (blocks, lines, words with boxes and confidences) per input")
+	fs.StringVar(&c.preprocess, "preprocess", c.preprocess, `Preprocessing chain: "none", "default", steps such as "resize:min=300:scale=2,grayscale,contrast:10", or a .json file (JSON only, no YAML). Images are only turned upright when the chain starts with "orient,deskew": orient applies the EXIF orientation, deskew corrects skews up to max_angle (10, at most 45) degrees, so scans turned 90 or 180 degrees without EXIF stay as they are`)
+	fs.Var((*stringList)(&c.variants), "variant", `Preprocessing variant "name=chain", repeatable; each image is OCRed with every variant and the most confident result kept`)
+	fs.Float64Var(&c.quality.MinSharpness, "min-sharpness", c.quality.MinSharpness, "Reject images whose Laplacian variance is lower as too_blurry (0 = disabled)")
+	fs.Float64Var(&c.quality.MinContrast, "min-contrast", c.quality.MinContrast, "Reject images whose luminance standard deviation is lower as blank_page (0 = disabled)")
+	fs.Float64Var(&c.quality.MinInk, "min-ink", c.quality.MinInk, "Reject images with a smaller fraction of dark pixels as blank_page (0 = disabled)")
+	fs.Float64Var(&c.quality.MaxInk, "max-ink", c.quality.MaxInk, "Reject images with a larger fraction of dark pixels as black_frame (0 = disabled)")
+	fs.Float64Var(&c.quality.MinBrightness, "min-brightness", c.quality.MinBrightness, "Reject images whose mean luminance (0-255) is lower as black_frame (0 = disabled)")
+	fs.StringVar(&c.tesseract.languages, "lang", c.tesseract.languages, `Tesseract languages such as "eng+deu" (default eng)`)
+	fs.StringVar(&c.tesseract.tessdata, "tessdata", c.tesseract.tessdata, "Directory of the Tesseract traineddata files (default $TESSDATA_PREFIX)")
+	fs.IntVar(&c.tesseract.psm, "psm", c.tesseract.psm, "Tesseract page segmentation mode 0-13 (default 3, automatic)")
+	fs.IntVar(&c.tesseract.oem, "oem", c.tesseract.oem, "Tesseract OCR engine mode 0-3 (default 3)")
+	fs.StringVar(&c.tesseract.whitelist, "whitelist", c.tesseract.whitelist, "Only let Tesseract recognize these characters")
+	fs.StringVar(&c.tesseract.blacklist, "blacklist", c.tesseract.blacklist, "Never let Tesseract recognize these characters")
+	fs.StringVar(&c.tesseract.configFile, "tess-config", c.tesseract.configFile, "Tesseract config file read at initialization")
+	fs.Var((*stringList)(&c.tesseract.variables), "tess-var", "Tesseract variable key=value, repeatable")
+	fs.StringVar(&c.ollama.baseURL, "ollama-url", c.ollama.baseURL, "Ollama server URL (default $OLLAMA_HOST, then http://localhost:11434)")
+	fs.StringVar(&c.ollama.model, "ollama-model", c.ollama.model, "Ollama vision model (default $OLLAMA_MODEL, then llama3.2-vision)")
+	fs.StringVar(&c.ollama.keepAlive, "ollama-keep-alive", c.ollama.keepAlive, `How long Ollama keeps the model loaded, such as "10m", or seconds, negative for ever (default $OLLAMA_KEEP_ALIVE)`)
+	fs.Float64Var(&c.ollama.temperature, "temperature", c.ollama.temperature, "Ollama sampling temperature, 0 for deterministic answers (default: the model's)")
+	fs.IntVar(&c.ollama.seed, "seed", c.ollama.seed, "Ollama random seed, pin it with --temperature 0 for reproducible runs")
+	fs.IntVar(&c.ollama.numCtx, "num-ctx", c.ollama.numCtx, "Ollama context window in tokens (default: the model's)")
+	fs.IntVar(&c.ollama.numPredict, "num-predict", c.ollama.numPredict, "Most tokens Ollama generates, -1 for no limit (default: the model's)")
 
 	if err := fs.Parse(args); err != nil {
 		return fmt.Errorf("parsing flags: %w", err)
 	}
 
-	// Set output file based on engine type
-	c.outputFile = fmt.Sprintf("%s/%s_extracted_data.csv", c.outputDir, c.engineType)
+	// Settings are layered: defaults, the environment, the config file, then
+	// the flags
+	ollama := &c.options.Engine.Ollama
+	applyOllamaEnv(ollama)
+	if c.configPath != "" {
+		cfg, err := loadConfig(c.configPath, fileConfig{EngineOptions: ocr.EngineOptions{Ollama: *ollama}})
+		if err != nil {
+			return err
+		}
+		c.options.Preprocess = cfg.Preprocess
+		c.options.Variants = cfg.Variants
+		c.options.Quality = cfg.Quality
+		c.options.Engine.Gosseract = cfg.Gosseract
+		c.options.Engine.Ollama = cfg.Ollama
+	}
+	gosseract := &c.options.Engine.Gosseract
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "ollama-url":
+			ollama.BaseURL = c.ollama.baseURL
+		case "ollama-model":
+			ollama.Model = c.ollama.model
+		case "ollama-keep-alive":
+			ollama.KeepAlive = c.ollama.keepAlive
+		case "temperature":
+			ollama.Options.Temperature = &c.ollama.temperature
+		case "seed":
+			ollama.Options.Seed = &c.ollama.seed
+		case "num-ctx":
+			ollama.Options.NumCtx = &c.ollama.numCtx
+		case "num-predict":
+			ollama.Options.NumPredict = &c.ollama.numPredict
+		case "lang":
+			gosseract.Languages = splitLanguages(c.tesseract.languages)
+		case "tessdata":
+			gosseract.Tessdata = c.tesseract.tessdata
+		case "psm":
+			gosseract.PageSegMode = &c.tesseract.psm
+		case "oem":
+			gosseract.EngineMode = &c.tesseract.oem
+		case "whitelist":
+			gosseract.Whitelist = c.tesseract.whitelist
+		case "blacklist":
+			gosseract.Blacklist = c.tesseract.blacklist
+		case "tess-config":
+			gosseract.ConfigFile = c.tesseract.configFile
+		case "min-sharpness":
+			c.options.Quality.MinSharpness = c.quality.MinSharpness
+		case "min-contrast":
+			c.options.Quality.MinContrast = c.quality.MinContrast
+		case "min-ink":
+			c.options.Quality.MinInk = c.quality.MinInk
+		case "max-ink":
+			c.options.Quality.MaxInk = c.quality.MaxInk
+		case "min-brightness":
+			c.options.Quality.MinBrightness = c.quality.MinBrightness
+		}
+	})
+	if len(c.tesseract.variables) > 0 {
+		variables, err := parseVariables(c.tesseract.variables)
+		if err != nil {
+			return fmt.Errorf("invalid --tess-var: %w", err)
+		}
+		if gosseract.Variables == nil {
+			gosseract.Variables = make(map[string]string)
+		}
+		for key, value := range variables {
+			gosseract.Variables[key] = value
+		}
+	}
+	if err := c.options.Quality.Validate(); err != nil {
+		return fmt.Errorf("invalid quality thresholds: %w", err)
+	}
+	if err := c.options.Engine.Validate(c.engineType); err != nil {
+		return fmt.Errorf("invalid %s options: %w", c.engineType, err)
+	}
+	if c.preprocess != "" {
+		chain, err := parsePreprocess(c.preprocess)
+		if err != nil {
+			return fmt.Errorf("invalid --preprocess: %w", err)
+		}
+		c.options.Preprocess = chain
+	}
+	if len(c.variants) > 0 {
+		c.options.Variants = nil
+		for _, value := range c.variants {
+			variant, err := parseVariant(value)
+			if err != nil {
+				return fmt.Errorf("invalid --variant: %w", err)
+			}
+			c.options.Variants = append(c.options.Variants, variant)
+		}
+	}
+	if err := pipeline.ValidateVariants(c.options.Variants); err != nil {
+		return err
+	}
+
+	// The manifest sits next to the output files and journals every input
+	c.options.ManifestPath = fmt.Sprintf("%s/%s_manifest.jsonl", c.outputDir, c.engineType)
 
-	return c.process_new()
+	// Set one output file per format based on engine type
+	var sinks []writer.Sink[data.ExtractedData]
+	for _, name := range strings.Split(c.format, ",") {
+		format, err := writer.ParseFormat(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		outputFile := fmt.Sprintf("%s/%s_extracted_data.%s", c.outputDir, c.engineType, format.Extension())
+		if format == writer.FormatCSV {
+			// Rows are appended, fail before any OCR if they would not fit
+			if err := writer.CheckCSVHeader(outputFile, data.GetCSVHeader()); err != nil {
+				return err
+			}
+		}
+		c.outputFiles = append(c.outputFiles, outputFile)
+		sinks = append(sinks, pipeline.NewSink(format, outputFile))
+	}
+
+	return c.process_new(ctx, sinks)
 }
 
-func (c *CLI) process_new() error {
-	results, errors := pipeline.Run(c.engineType, c.imagesDir, c.outputFile)
-	for path, err := range errors {
+func (c *CLI) process_new(ctx context.Context, sinks []writer.Sink[data.ExtractedData]) error {
+	results, failures := pipeline.Run(ctx, c.engineType, c.imagesDir, c.options, sinks...)
+	skipped, timedOut := 0, 0
+	for path, err := range failures {
+		if errors.Is(err, pipeline.ErrSkipped) {
+			skipped++
+			continue
+		}
+		if errors.Is(err, pipeline.ErrTimeout) {
+			timedOut++
+		}
 		fmt.Printf("Error processing %s: %v\n", path, err)
 	}
 	for path, data := range results {
 		fmt.Printf("Processed %s: %v\n", path, data)
 	}
-	fmt.Printf("\nProcessing complete! Results saved to: %s\n", c.outputFile)
-	fmt.Printf("Processed %d records\n", len(results)+len(errors))
+
+	if ctx.Err() != nil {
+		fmt.Printf("\nProcessing interrupted! Partial results saved to: %s\n", strings.Join(c.outputFiles, ", "))
+	} else {
+		fmt.Printf("\nProcessing complete! Results saved to: %s\n", strings.Join(c.outputFiles, ", "))
+	}
+	fmt.Printf("Completed %d, failed %d (timed out %d), skipped %d\n", len(results), len(failures)-skipped, timedOut, skipped)
+
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	return nil
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
 	return nil
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"ocr-tool/internal/image"
+	"ocr-tool/internal/ocr"
+	"ocr-tool/internal/ocr/engine"
+	"ocr-tool/internal/pipeline"
+	"os"
+	"strings"
+)
+
+// fileConfig is the JSON run configuration read with --config. Flags given
+// on the command line take precedence over it.
+type fileConfig struct {
+	ocr.EngineOptions // "gosseract" and "ollama" settings, see engine.GosseractOptions and engine.OllamaOptions
+
+	Preprocess image.Chain             `json:"preprocess"` // steps or a chain string, see image.ParseChain
+	Variants   []pipeline.Variant      `json:"variants"`   // alternative chains, replacing preprocess
+	Quality    image.QualityThresholds `json:"quality"`    // rejection before OCR, see image.QualityThresholds
+}
+
+// loadConfig decodes the file over cfg, which keeps the values the file
+// leaves out.
+func loadConfig(path string, cfg fileConfig) (fileConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return cfg, fmt.Errorf("opening config: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// parsePreprocess reads the --preprocess value, a chain in the CLI form or
+// the path of a JSON file holding one.
+func parsePreprocess(value string) (image.Chain, error) {
+	if strings.HasSuffix(strings.ToLower(value), ".json") {
+		return image.LoadChain(value)
+	}
+	return image.ParseChain(value)
+}
+
+// parseVariant reads a --variant value, "name=chain" where the chain is
+// anything --preprocess accepts.
+func parseVariant(value string) (pipeline.Variant, error) {
+	name, spec, ok := strings.Cut(value, "=")
+	if !ok {
+		return pipeline.Variant{}, fmt.Errorf("%q is not name=chain", value)
+	}
+	chain, err := parsePreprocess(spec)
+	if err != nil {
+		return pipeline.Variant{}, fmt.Errorf("variant %s: %w", name, err)
+	}
+	return pipeline.Variant{Name: strings.TrimSpace(name), Preprocess: chain}, nil
+}
+
+// parseVariables reads repeated --tess-var key=value flags.
+func parseVariables(values []string) (map[string]string, error) {
+	variables := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("%q is not key=value", value)
+		}
+		variables[strings.TrimSpace(key)] = val
+	}
+	return variables, nil
+}
+
+// splitLanguages accepts Tesseract's "eng+deu" as well as "eng,deu".
+func splitLanguages(value string) []string {
+	var languages []string
+	for _, lang := range strings.FieldsFunc(value, func(r rune) bool { return r == '+' || r == ',' }) {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			languages = append(languages, lang)
+		}
+	}
+	return languages
+}
+
+// applyOllamaEnv reads the Ollama options from the environment, which the
+// config file and flags override: OLLAMA_HOST read as the Ollama CLI does,
+// where a bare host uses port 11434, OLLAMA_MODEL and OLLAMA_KEEP_ALIVE.
+func applyOllamaEnv(options *engine.OllamaOptions) {
+	if host := os.Getenv("OLLAMA_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			if _, _, err := net.SplitHostPort(host); err != nil {
+				host = net.JoinHostPort(host, "11434")
+			}
+			host = "http://" + host
+		}
+		options.BaseURL = host
+	}
+	if model := os.Getenv("OLLAMA_MODEL"); model != "" {
+		options.Model = model
+	}
+	if keepAlive := os.Getenv("OLLAMA_KEEP_ALIVE"); keepAlive != "" {
+		options.KeepAlive = keepAlive
+	}
+}
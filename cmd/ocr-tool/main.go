@@ -1,15 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
+// exitInterrupted follows the shell convention of 128 + SIGINT.
+const exitInterrupted = 130
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		// Restore default handling so a second signal kills the process
+		stop()
+		log.Println("Interrupted, writing completed results and waiting for running recognitions (press Ctrl-C again to force quit)")
+	}()
+
 	cli := NewCLI()
-	if err := cli.Run(os.Args[1:]); err != nil {
+	if err := cli.Run(ctx, os.Args[1:]); err != nil {
+		if errors.Is(err, errInterrupted) {
+			os.Exit(exitInterrupted)
+		}
 		log.Fatal("Error:", err)
 	}
 }
@@ -5,6 +5,5 @@ go 1.23.3
 require (
 	github.com/disintegration/imaging v1.6.2
 	github.com/otiai10/gosseract/v2 v2.4.1
+	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8
 )
-
-require golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8 // indirect
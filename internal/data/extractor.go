@@ -1,18 +1,29 @@
 package data
 
 import (
-	"encoding/json"
+	"ocr-tool/internal/ocr"
+	"ocr-tool/internal/ocr/layout"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
 type ExtractedData struct {
-	Filename string   `json:"Filename,omitempty"`
-	Name     string   `json:"Name,omitempty"`
-	Email    string   `json:"Email,omitempty"`
-	Phone    string   `json:"Phone,omitempty"`
-	Tags     []string `json:"Tags,omitempty"`
-	Text     string   `json:"Text,omitempty"`
+	Filename   string   `json:"Filename,omitempty"`
+	Page       int      `json:"Page,omitempty"`
+	Rotation   float64  `json:"Rotation,omitempty"`   // degrees counter-clockwise applied before OCR
+	Variant    string   `json:"Variant,omitempty"`    // preprocessing variant the result was kept from
+	Confidence float64  `json:"Confidence,omitempty"` // engine confidence from 0 to 100
+	Attempts   int      `json:"Attempts,omitempty"`   // engine calls made, more than 1 after transient failures
+	Name       string   `json:"Name,omitempty"`
+	Email      string   `json:"Email,omitempty"`
+	Phone      string   `json:"Phone,omitempty"`
+	Tags       []string `json:"Tags,omitempty"`
+	Text       string   `json:"Text,omitempty"`
+
+	// FieldConfidence is the lowest word confidence of each field found in
+	// an engine layout, telling which values are shaky
+	FieldConfidence map[string]float64 `json:"FieldConfidence,omitempty"`
 }
 
 type DataExtractor struct{}
@@ -26,41 +37,46 @@ func NewDataExtractor() *DataExtractor {
 	return &DataExtractor{}
 }
 
-func (de *DataExtractor) ExtractFromJson(data json.RawMessage, filename string) *ExtractedData {
-	dataStr := string(data)
-
-	extractedData := ExtractedData{
-		Filename: filename,
-		Name:     "",
-		Email:    "",
-		Phone:    "",
-		Tags:     []string{},
-		Text:     "",
-	}
-	if err := json.Unmarshal([]byte(dataStr), &extractedData); err != nil {
-		return &ExtractedData{
+// Extract reads the contact details of a document. Fields the engine read
+// itself are cleaned up, otherwise they are searched in the text.
+func (de *DataExtractor) Extract(doc ocr.Document, filename string) *ExtractedData {
+	var result *ExtractedData
+	if doc.Fields != nil {
+		result = &ExtractedData{
 			Filename: filename,
-			Text:     dataStr,
+			Name:     doc.Fields.Name,
+			Email:    de.extractEmail(doc.Fields.Email),
+			Phone:    de.extractPhone(doc.Fields.Phone),
+			Tags:     doc.Fields.Tags,
+			Text:     doc.Text,
 		}
-	}
-
-	if extractedData.Text != "" {
-		return &ExtractedData{
+	} else {
+		result = &ExtractedData{
 			Filename: filename,
-			Name:     extractedData.Name,
-			Email:    de.extractEmail(extractedData.Text),
-			Phone:    de.extractPhone(extractedData.Text),
-			Tags:     de.extractTags(extractedData.Text),
-			Text:     extractedData.Text,
+			Email:    de.extractEmail(doc.Text),
+			Phone:    de.extractPhone(doc.Text),
+			Tags:     de.extractTags(doc.Text),
+			Text:     doc.Text,
 		}
 	}
+	if doc.Layout != nil {
+		de.scoreFields(result, *doc.Layout)
+	}
+	return result
+}
 
-	return &ExtractedData{
-		Filename: filename,
-		Name:     extractedData.Name,
-		Email:    de.extractEmail(extractedData.Email),
-		Phone:    de.extractPhone(extractedData.Phone),
-		Tags:     extractedData.Tags,
+// scoreFields fills FieldConfidence from the word confidences of the layout.
+func (de *DataExtractor) scoreFields(result *ExtractedData, page layout.Layout) {
+	for field, value := range map[string]string{"Name": result.Name, "Email": result.Email, "Phone": result.Phone} {
+		if value == "" {
+			continue
+		}
+		if confidence, ok := page.ConfidenceOf(value); ok {
+			if result.FieldConfidence == nil {
+				result.FieldConfidence = make(map[string]float64)
+			}
+			result.FieldConfidence[field] = confidence
+		}
 	}
 }
 
@@ -135,8 +151,29 @@ func (de *DataExtractor) cleanOCRText(text string) string {
 }
 
 func MapCSVRecord(item ExtractedData) []string {
+	page := ""
+	if item.Page > 0 {
+		page = strconv.Itoa(item.Page)
+	}
+	rotation := ""
+	if item.Rotation != 0 {
+		rotation = strconv.FormatFloat(item.Rotation, 'f', -1, 64)
+	}
+	confidence := ""
+	if item.Confidence > 0 {
+		confidence = strconv.FormatFloat(item.Confidence, 'f', 1, 64)
+	}
+	attempts := ""
+	if item.Attempts > 0 {
+		attempts = strconv.Itoa(item.Attempts)
+	}
 	return []string{
 		item.Filename,
+		page,
+		rotation,
+		item.Variant,
+		confidence,
+		attempts,
 		item.Name,
 		item.Email,
 		item.Phone,
@@ -146,5 +183,5 @@ func MapCSVRecord(item ExtractedData) []string {
 }
 
 func GetCSVHeader() []string {
-	return []string{"Filename", "Name", "Email", "Phone", "Tags", "Text"}
+	return []string{"Filename", "Page", "Rotation", "Variant", "Confidence", "Attempts", "Name", "Email", "Phone", "Tags", "Text"}
 }
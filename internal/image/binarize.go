@@ -0,0 +1,172 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// toGray converts img to 8-bit luminance with its origin at (0, 0).
+func toGray(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok && gray.Rect.Min == (image.Point{}) {
+		return gray
+	}
+	nrgba := imaging.Grayscale(img)
+	gray := image.NewGray(nrgba.Bounds())
+	for i := range gray.Pix {
+		gray.Pix[i] = nrgba.Pix[i*4]
+	}
+	return gray
+}
+
+// OtsuLevel returns the global threshold that maximizes the variance between
+// the dark and the light class of the histogram.
+func OtsuLevel(gray *image.Gray) uint8 {
+	var histogram [256]float64
+	for _, v := range gray.Pix {
+		histogram[v]++
+	}
+
+	total := float64(len(gray.Pix))
+	var sum float64
+	for v, count := range histogram {
+		sum += float64(v) * count
+	}
+
+	var level uint8
+	var darkCount, darkSum, best float64
+	for v := 0; v < 256; v++ {
+		darkCount += histogram[v]
+		if darkCount == 0 {
+			continue
+		}
+		lightCount := total - darkCount
+		if lightCount == 0 {
+			break
+		}
+		darkSum += float64(v) * histogram[v]
+		darkMean := darkSum / darkCount
+		lightMean := (sum - darkSum) / lightCount
+		if between := darkCount * lightCount * (darkMean - lightMean) * (darkMean - lightMean); between > best {
+			best, level = between, uint8(v)
+		}
+	}
+	return level
+}
+
+// Otsu binarizes the image with OtsuLevel: pixels above it become white.
+func Otsu(img image.Image) *image.Gray {
+	gray := toGray(img)
+	level := OtsuLevel(gray)
+	return binarize(gray, func(int, int) float64 { return float64(level) + 0.5 })
+}
+
+// Sauvola binarizes the image against a local threshold
+// mean * (1 + k * (stddev/r - 1)) over a window x window neighbourhood. It
+// copes with uneven lighting where a global threshold blackens the shadows.
+func Sauvola(img image.Image, window int, k, r float64) *image.Gray {
+	gray := toGray(img)
+	stats := newWindowStats(gray, window)
+	return binarize(gray, func(x, y int) float64 {
+		mean, stddev := stats.at(x, y)
+		return mean * (1 + k*(stddev/r-1))
+	})
+}
+
+// Niblack binarizes the image against a local threshold mean + k * stddev
+// over a window x window neighbourhood. A negative k keeps thin strokes.
+func Niblack(img image.Image, window int, k float64) *image.Gray {
+	gray := toGray(img)
+	stats := newWindowStats(gray, window)
+	return binarize(gray, func(x, y int) float64 {
+		mean, stddev := stats.at(x, y)
+		return mean + k*stddev
+	})
+}
+
+func binarize(gray *image.Gray, level func(x, y int) float64) *image.Gray {
+	bounds := gray.Bounds()
+	out := image.NewGray(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			if float64(gray.Pix[y*gray.Stride+x]) > level(x, y) {
+				out.Pix[y*out.Stride+x] = 0xff
+			}
+		}
+	}
+	return out
+}
+
+// windowStats answers local mean and standard deviation queries in constant
+// time from integral images of the values and their squares.
+type windowStats struct {
+	sum, squares  []float64
+	width, height int
+	radius        int
+}
+
+func newWindowStats(gray *image.Gray, window int) *windowStats {
+	bounds := gray.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	s := &windowStats{
+		sum:     make([]float64, (w+1)*(h+1)),
+		squares: make([]float64, (w+1)*(h+1)),
+		width:   w,
+		height:  h,
+		radius:  max(window/2, 1),
+	}
+
+	for y := 0; y < h; y++ {
+		var rowSum, rowSquares float64
+		for x := 0; x < w; x++ {
+			v := float64(gray.Pix[y*gray.Stride+x])
+			rowSum += v
+			rowSquares += v * v
+			i := (y+1)*(w+1) + x + 1
+			s.sum[i] = s.sum[i-(w+1)] + rowSum
+			s.squares[i] = s.squares[i-(w+1)] + rowSquares
+		}
+	}
+	return s
+}
+
+func (s *windowStats) at(x, y int) (mean, stddev float64) {
+	x0, y0 := max(x-s.radius, 0), max(y-s.radius, 0)
+	x1, y1 := min(x+s.radius+1, s.width), min(y+s.radius+1, s.height)
+	area := float64((x1 - x0) * (y1 - y0))
+
+	rect := func(table []float64) float64 {
+		stride := s.width + 1
+		return table[y1*stride+x1] - table[y0*stride+x1] - table[y1*stride+x0] + table[y0*stride+x0]
+	}
+	mean = rect(s.sum) / area
+	variance := rect(s.squares)/area - mean*mean
+	return mean, math.Sqrt(max(variance, 0))
+}
+
+func sauvolaStep(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+	return Sauvola(img, int(p["window"]), p["k"], p["r"]), nil
+}
+
+func niblackStep(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+	return Niblack(img, int(p["window"]), p["k"]), nil
+}
+
+func checkSauvola(p map[string]float64) error {
+	if err := checkWindow(p); err != nil {
+		return err
+	}
+	if p["r"] <= 0 {
+		return fmt.Errorf("r must be positive")
+	}
+	return nil
+}
+
+func checkWindow(p map[string]float64) error {
+	if p["window"] < 3 {
+		return fmt.Errorf("window must be at least 3 pixels")
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package image
+
+import (
+	"image"
+	"testing"
+)
+
+// unevenlyLitPage draws text strokes on a page lit from the left: the
+// background fades from 250 to 60, the ink is always 45 levels darker than
+// its surroundings. It returns the page and the mask of ink pixels.
+func unevenlyLitPage(width, height int) (*image.Gray, []bool) {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	ink := make([]bool, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			background := 250 - 190*float64(x)/float64(width-1)
+			i := y*width + x
+			// 3 pixel strokes every 12 pixels, in bands of text lines
+			ink[i] = x%12 < 3 && y%20 >= 5 && y%20 < 15
+			if ink[i] {
+				background -= 45
+			}
+			img.Pix[i] = uint8(background)
+		}
+	}
+	return img, ink
+}
+
+// accuracy returns the fraction of pixels classified as expected: ink black
+// and background white.
+func accuracy(binary *image.Gray, ink []bool) float64 {
+	correct := 0
+	for i, v := range binary.Pix {
+		if (v == 0) == ink[i] {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(ink))
+}
+
+func TestOtsuLevel(t *testing.T) {
+	// Arrange: two populations around 40 and 200
+	img := image.NewGray(image.Rect(0, 0, 100, 10))
+	for i := range img.Pix {
+		img.Pix[i] = 40 + uint8(i%10)
+		if i%100 >= 30 {
+			img.Pix[i] = 200 + uint8(i%10)
+		}
+	}
+
+	// Act
+	level := OtsuLevel(img)
+
+	// Assert
+	if level < 49 || level >= 200 {
+		t.Errorf("expected a level between the two populations, got %d", level)
+	}
+	binary := Otsu(img)
+	if binary.Pix[0] != 0 || binary.Pix[99] != 0xff {
+		t.Errorf("expected dark pixels black and light ones white, got %d and %d", binary.Pix[0], binary.Pix[99])
+	}
+}
+
+func TestBinarize_GradientLighting(t *testing.T) {
+	img, ink := unevenlyLitPage(240, 80)
+
+	testCases := []struct {
+		name     string
+		binarize func(image.Image) *image.Gray
+		minimum  float64
+	}{
+		{name: "sauvola", binarize: func(img image.Image) *image.Gray { return Sauvola(img, 25, 0.2, 128) }, minimum: 0.97},
+		{name: "niblack", binarize: func(img image.Image) *image.Gray { return Niblack(img, 25, -0.2) }, minimum: 0.9},
+	}
+
+	// A global threshold cannot separate ink on the bright side from the
+	// background on the dark side
+	global := accuracy(Otsu(img), ink)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			actual := accuracy(tc.binarize(img), ink)
+
+			// Assert
+			if actual < tc.minimum {
+				t.Errorf("expected accuracy of at least %.2f, got %.3f", tc.minimum, actual)
+			}
+			if actual <= global {
+				t.Errorf("expected to beat global Otsu (%.3f), got %.3f", global, actual)
+			}
+		})
+	}
+}
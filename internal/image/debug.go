@@ -0,0 +1,70 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+)
+
+// debugSidecar is the steps.json written next to the step images.
+type debugSidecar struct {
+	Input       string      `json:"input"`
+	Orientation int         `json:"orientation,omitempty"`
+	Rotation    float64     `json:"rotation,omitempty"`
+	TotalMillis float64     `json:"total_ms"`
+	Steps       []debugStep `json:"steps"`
+}
+
+type debugStep struct {
+	Step   string             `json:"step"`
+	Params map[string]float64 `json:"params,omitempty"`
+	Millis float64            `json:"duration_ms"`
+	Image  string             `json:"image,omitempty"`
+}
+
+// WriteDebugImages saves the source and the output of every step of an
+// image enhanced with TraceSteps into dir/<name>/, as 00-source.png,
+// 01-<step>.png and so on, along with a steps.json sidecar holding the step
+// parameters and timings.
+func WriteDebugImages(dir, name string, enhanced Enhanced) error {
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("creating debug directory: %w", err)
+	}
+
+	sidecar := debugSidecar{
+		Input:       name,
+		Orientation: enhanced.Orientation,
+		Rotation:    enhanced.Rotation,
+		Steps:       make([]debugStep, 0, len(enhanced.Steps)),
+	}
+	if enhanced.Source != nil {
+		if err := imaging.Save(enhanced.Source, filepath.Join(target, "00-source.png")); err != nil {
+			return fmt.Errorf("saving debug image: %w", err)
+		}
+	}
+	for i, step := range enhanced.Steps {
+		entry := debugStep{
+			Step:   step.Name,
+			Params: step.Params,
+			Millis: float64(step.Duration.Microseconds()) / 1000,
+		}
+		if step.Image != nil {
+			entry.Image = fmt.Sprintf("%02d-%s.png", i+1, step.Name)
+			if err := imaging.Save(step.Image, filepath.Join(target, entry.Image)); err != nil {
+				return fmt.Errorf("saving debug image: %w", err)
+			}
+		}
+		sidecar.TotalMillis += entry.Millis
+		sidecar.Steps = append(sidecar.Steps, entry)
+	}
+
+	content, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(target, "steps.json"), content, 0644)
+}
@@ -0,0 +1,59 @@
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteDebugImages(t *testing.T) {
+	// Arrange
+	var pngBytes bytes.Buffer
+	if err := png.Encode(&pngBytes, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatalf("encoding PNG failed: %v", err)
+	}
+	chain, err := ParseChain("grayscale,threshold:100")
+	if err != nil {
+		t.Fatalf("parsing chain failed: %v", err)
+	}
+	processor := NewImageProcessor(chain)
+	processor.TraceSteps()
+	enhanced, err := processor.EnhanceQuality(bytes.NewReader(pngBytes.Bytes()), 0)
+	if err != nil {
+		t.Fatalf("enhancing failed: %v", err)
+	}
+	dir := t.TempDir()
+
+	// Act
+	err = WriteDebugImages(dir, "batch.zip!/scans/a.png", enhanced)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("writing debug images failed: %v", err)
+	}
+	target := filepath.Join(dir, "batch.zip!", "scans", "a.png")
+	for _, name := range []string{"00-source.png", "01-grayscale.png", "02-threshold.png"} {
+		if _, err := os.Stat(filepath.Join(target, name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(target, "steps.json"))
+	if err != nil {
+		t.Fatalf("reading sidecar failed: %v", err)
+	}
+	var sidecar debugSidecar
+	if err := json.Unmarshal(content, &sidecar); err != nil {
+		t.Fatalf("parsing sidecar failed: %v", err)
+	}
+	if sidecar.Input != "batch.zip!/scans/a.png" || len(sidecar.Steps) != 2 {
+		t.Fatalf("unexpected sidecar: %s", content)
+	}
+	if step := sidecar.Steps[1]; step.Step != "threshold" || step.Params["level"] != 100 || step.Image != "02-threshold.png" {
+		t.Errorf("unexpected threshold entry: %+v", step)
+	}
+}
@@ -0,0 +1,144 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"slices"
+
+	"github.com/disintegration/imaging"
+)
+
+// inkLevel separates ink from paper in images that are already binarized.
+const inkLevel = 128
+
+// Despeckle replaces every pixel by the median of its (2*radius+1)² window,
+// which removes isolated specks while keeping the edges of strokes.
+func Despeckle(img image.Image, radius int) *image.Gray {
+	gray := toGray(img)
+	bounds := gray.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	out := image.NewGray(bounds)
+
+	window := make([]uint8, 0, (2*radius+1)*(2*radius+1))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			window = window[:0]
+			for dy := max(y-radius, 0); dy <= min(y+radius, h-1); dy++ {
+				row := gray.Pix[dy*gray.Stride:]
+				window = append(window, row[max(x-radius, 0):min(x+radius, w-1)+1]...)
+			}
+			slices.Sort(window)
+			out.Pix[y*out.Stride+x] = window[len(window)/2]
+		}
+	}
+	return out
+}
+
+// RemoveBlobs whitens the 8-connected groups of dark pixels made of at most
+// maxSize pixels. It is meant for binarized images, where noise is left as
+// small blobs next to much larger characters.
+func RemoveBlobs(img image.Image, maxSize int) *image.Gray {
+	gray := toGray(img)
+	bounds := gray.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	out := image.NewGray(bounds)
+	copy(out.Pix, gray.Pix)
+
+	visited := make([]bool, w*h)
+	var component, stack []int
+	for start := range visited {
+		if visited[start] || gray.Pix[start/w*gray.Stride+start%w] >= inkLevel {
+			continue
+		}
+
+		component, stack = component[:0], append(stack[:0], start)
+		visited[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			component = append(component, i)
+
+			x, y := i%w, i/w
+			for ny := max(y-1, 0); ny <= min(y+1, h-1); ny++ {
+				for nx := max(x-1, 0); nx <= min(x+1, w-1); nx++ {
+					n := ny*w + nx
+					if !visited[n] && gray.Pix[ny*gray.Stride+nx] < inkLevel {
+						visited[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+
+		if len(component) <= maxSize {
+			for _, i := range component {
+				out.Pix[i/w*out.Stride+i%w] = 0xff
+			}
+		}
+	}
+	return out
+}
+
+// BorderBounds finds the scanner borders of the image: rows and columns at
+// an edge whose fraction of dark pixels is at least dark. At most limit of
+// the width and height is trimmed from each side.
+func BorderBounds(img image.Image, dark, limit float64) image.Rectangle {
+	gray := toGray(img)
+	bounds := gray.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	rowDark := func(y, x0, x1 int) bool {
+		count := 0
+		for x := x0; x < x1; x++ {
+			if gray.Pix[y*gray.Stride+x] < inkLevel {
+				count++
+			}
+		}
+		return float64(count) >= dark*float64(x1-x0)
+	}
+	colDark := func(x, y0, y1 int) bool {
+		count := 0
+		for y := y0; y < y1; y++ {
+			if gray.Pix[y*gray.Stride+x] < inkLevel {
+				count++
+			}
+		}
+		return float64(count) >= dark*float64(y1-y0)
+	}
+
+	maxX, maxY := int(limit*float64(w)), int(limit*float64(h))
+	top, bottom, left, right := 0, h, 0, w
+	for top < maxY && rowDark(top, 0, w) {
+		top++
+	}
+	for h-bottom < maxY && bottom > top+1 && rowDark(bottom-1, 0, w) {
+		bottom--
+	}
+	for left < maxX && colDark(left, top, bottom) {
+		left++
+	}
+	for w-right < maxX && right > left+1 && colDark(right-1, top, bottom) {
+		right--
+	}
+	return image.Rect(left, top, right, bottom)
+}
+
+func despeckleStep(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+	return Despeckle(img, int(p["radius"])), nil
+}
+
+func removeBlobsStep(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+	return RemoveBlobs(img, int(p["max_size"])), nil
+}
+
+func checkCropBorders(p map[string]float64) error {
+	if p["dark"] <= 0 || p["dark"] > 1 || p["limit"] < 0 || p["limit"] >= 0.5 {
+		return fmt.Errorf("dark must be in (0, 1] and limit in [0, 0.5)")
+	}
+	return nil
+}
+
+func cropBordersStep(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+	rect := BorderBounds(img, p["dark"], p["limit"])
+	return imaging.Crop(img, rect.Add(img.Bounds().Min)), nil
+}
@@ -0,0 +1,103 @@
+package image
+
+import (
+	"image"
+	"testing"
+)
+
+// speckledPage returns a white image with a dark 10x10 square at (20, 20) and
+// single dark pixels sprinkled every 7 pixels away from it.
+func speckledPage() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 60, 60))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for y := 20; y < 30; y++ {
+		for x := 20; x < 30; x++ {
+			img.Pix[y*img.Stride+x] = 0
+		}
+	}
+	for y := 3; y < 60; y += 7 {
+		for x := 3; x < 60; x += 7 {
+			if x < 17 || x > 32 || y < 17 || y > 32 {
+				img.Pix[y*img.Stride+x] = 0
+			}
+		}
+	}
+	return img
+}
+
+func darkPixels(img *image.Gray) int {
+	count := 0
+	for _, v := range img.Pix {
+		if v < inkLevel {
+			count++
+		}
+	}
+	return count
+}
+
+func TestDenoise(t *testing.T) {
+	testCases := []struct {
+		name  string
+		apply func(image.Image) *image.Gray
+	}{
+		{name: "despeckle", apply: func(img image.Image) *image.Gray { return Despeckle(img, 1) }},
+		{name: "remove blobs", apply: func(img image.Image) *image.Gray { return RemoveBlobs(img, 4) }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			img := speckledPage()
+
+			// Act
+			actual := tc.apply(img)
+
+			// Assert: the specks are gone, the square survives (the median
+			// rounds its four corners off)
+			if dark := darkPixels(actual); dark < 96 || dark > 100 {
+				t.Errorf("expected only the square to stay dark, got %d dark pixels", dark)
+			}
+			if actual.Pix[25*actual.Stride+25] != 0 {
+				t.Error("expected the square to stay dark")
+			}
+		})
+	}
+}
+
+func TestBorderBounds(t *testing.T) {
+	// Arrange: a page with a 6 pixel black frame on the left and top, 3 on
+	// the right and a dark text row that must not be mistaken for a border
+	img := image.NewGray(image.Rect(0, 0, 100, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 100; x++ {
+			v := uint8(0xff)
+			if x < 6 || y < 6 || x >= 97 || (y == 40 && x%3 != 0) {
+				v = 0
+			}
+			img.Pix[y*img.Stride+x] = v
+		}
+	}
+
+	// Act
+	actual := BorderBounds(img, 0.5, 0.2)
+
+	// Assert
+	expected := image.Rect(6, 6, 97, 80)
+	if actual != expected {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	chain, err := ParseChain("crop_borders")
+	if err != nil {
+		t.Fatalf("parsing chain failed: %v", err)
+	}
+	cropped, _, err := chain.Apply(img, 1)
+	if err != nil {
+		t.Fatalf("applying chain failed: %v", err)
+	}
+	if cropped.Bounds().Size() != expected.Size() {
+		t.Errorf("expected a %v image, got %v", expected.Size(), cropped.Bounds().Size())
+	}
+}
@@ -0,0 +1,102 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// deskewSampleSize is the longest side the image is reduced to before the
+// skew is estimated, which keeps the estimate fast on full page scans.
+const deskewSampleSize = 800
+
+// deskew rotates the image so that its text lines are horizontal. Angles
+// beyond max_angle are not searched, those below min_angle are ignored.
+func deskew(img image.Image, p map[string]float64, st *chainState) (image.Image, error) {
+	angle := estimateSkew(img, p["max_angle"])
+	if math.Abs(angle) < p["min_angle"] {
+		return img, nil
+	}
+	st.rotate(angle)
+	return imaging.Rotate(img, angle, color.White), nil
+}
+
+// estimateSkew returns the angle in degrees, counter-clockwise, that makes
+// the text lines horizontal. It projects the dark pixels onto rows rotated by
+// each candidate angle and keeps the angle with the sharpest profile: when
+// the rows follow the text lines, ink and gaps alternate.
+func estimateSkew(img image.Image, maxAngle float64) float64 {
+	bounds := img.Bounds()
+	if longest := max(bounds.Dx(), bounds.Dy()); longest > deskewSampleSize {
+		scale := float64(deskewSampleSize) / float64(longest)
+		img = imaging.Resize(img, int(float64(bounds.Dx())*scale), 0, imaging.Box)
+	}
+	points := inkPoints(imaging.Grayscale(img))
+	if len(points) == 0 || maxAngle <= 0 {
+		return 0
+	}
+
+	best, bestScore := 0.0, profileScore(points, 0)
+	search := func(from, to, step float64) {
+		for angle := from; angle <= to+step/2; angle += step {
+			if score := profileScore(points, angle); score > bestScore {
+				best, bestScore = angle, score
+			}
+		}
+	}
+	search(-maxAngle, maxAngle, 0.5)
+	search(best-0.5, best+0.5, 0.1)
+	return math.Round(best*10) / 10
+}
+
+// inkPoints returns the coordinates of the pixels darker than the midpoint
+// between the darkest and the lightest pixel.
+func inkPoints(gray *image.NRGBA) [][2]float64 {
+	lo, hi := uint8(255), uint8(0)
+	for i := 0; i < len(gray.Pix); i += 4 {
+		lo, hi = min(lo, gray.Pix[i]), max(hi, gray.Pix[i])
+	}
+	if hi-lo < 32 {
+		// Blank or flat image, nothing to align
+		return nil
+	}
+	level := lo + (hi-lo)/2
+
+	var points [][2]float64
+	bounds := gray.Bounds()
+	for y := 0; y < bounds.Dy(); y++ {
+		row := gray.Pix[y*gray.Stride:]
+		for x := 0; x < bounds.Dx(); x++ {
+			if row[x*4] < level {
+				points = append(points, [2]float64{float64(x), float64(y)})
+			}
+		}
+	}
+	return points
+}
+
+// profileScore sums the squared differences between neighbouring rows of
+// the projection of points onto rows tilted by angle degrees.
+func profileScore(points [][2]float64, angle float64) float64 {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+
+	projected := make([]int, len(points))
+	lo, hi := math.MaxInt, math.MinInt
+	for i, p := range points {
+		projected[i] = int(math.Round(p[1]*cos - p[0]*sin))
+		lo, hi = min(lo, projected[i]), max(hi, projected[i])
+	}
+	rows := make([]float64, hi-lo+2)
+	for _, row := range projected {
+		rows[row-lo]++
+	}
+
+	var score float64
+	for i := 1; i < len(rows); i++ {
+		diff := rows[i] - rows[i-1]
+		score += diff * diff
+	}
+	return score
+}
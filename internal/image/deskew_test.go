@@ -0,0 +1,100 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// textLines draws dark horizontal bars resembling lines of text.
+func textLines(width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for y := 20; y+8 < height-20; y += 24 {
+		for dy := 0; dy < 8; dy++ {
+			for x := 20; x < width-20; x++ {
+				// Gaps between words
+				if x%60 < 50 {
+					img.SetGray(x, y+dy, color.Gray{})
+				}
+			}
+		}
+	}
+	return img
+}
+
+func TestEstimateSkew(t *testing.T) {
+	for _, skew := range []float64{0, 3, -4.5, 7} {
+		// Arrange: rotating clockwise by skew needs a counter-clockwise
+		// correction of the same amount
+		img := imaging.Rotate(textLines(600, 400), -skew, color.White)
+
+		// Act
+		actual := estimateSkew(img, 10)
+
+		// Assert
+		if math.Abs(actual-skew) > 0.3 {
+			t.Errorf("skew %.1f: estimated %.1f", skew, actual)
+		}
+	}
+}
+
+func TestChain_ApplyRecordsRotation(t *testing.T) {
+	// Arrange
+	img := imaging.Rotate(textLines(600, 400), -3, color.White)
+	chain, err := ParseChain("rotate:90,orient,deskew")
+	if err != nil {
+		t.Fatalf("parsing chain failed: %v", err)
+	}
+
+	// Act: orientation 6 needs 270°, which with the explicit 90° is upright
+	_, applied, err := chain.Apply(img, 6)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("applying chain failed: %v", err)
+	}
+	if math.Abs(applied.Rotation-3) > 0.3 {
+		t.Errorf("expected a total rotation of about 3 degrees, got %.2f", applied.Rotation)
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	// Arrange: a JPEG with an Exif APP1 segment holding orientation 6
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, image.NewGray(image.Rect(0, 0, 8, 4)), nil); err != nil {
+		t.Fatalf("encoding JPEG failed: %v", err)
+	}
+	exif := []byte("Exif\x00\x00" +
+		"MM\x00\x2a\x00\x00\x00\x08" + // big-endian TIFF header, IFD at 8
+		"\x00\x01" + // one entry
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00" + // orientation, SHORT, 6
+		"\x00\x00\x00\x00")
+	segment := append([]byte{0xff, 0xe1, byte((len(exif) + 2) >> 8), byte(len(exif) + 2)}, exif...)
+	rotated := append(append([]byte{0xff, 0xd8}, segment...), plain.Bytes()[2:]...)
+
+	// Act
+	actual := Orientation(rotated)
+
+	// Assert
+	if actual != 6 {
+		t.Fatalf("expected orientation 6, got %d", actual)
+	}
+	if Orientation(plain.Bytes()) != 1 {
+		t.Errorf("expected orientation 1 without Exif")
+	}
+	img, _, err := Chain{{Name: "orient"}}.Apply(image.NewGray(image.Rect(0, 0, 8, 4)), actual)
+	if err != nil {
+		t.Fatalf("applying chain failed: %v", err)
+	}
+	if img.Bounds().Dx() != 4 || img.Bounds().Dy() != 8 {
+		t.Errorf("expected a 4x8 upright image, got %v", img.Bounds())
+	}
+}
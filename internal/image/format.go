@@ -0,0 +1,93 @@
+package image
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+
+	"ocr-tool/internal/pdf"
+)
+
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatTIFF Format = "tiff"
+	FormatBMP  Format = "bmp"
+	FormatPDF  Format = "pdf"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+var (
+	tiffLittleEndian = []byte("II*\x00")
+	tiffBigEndian    = []byte("MM\x00*")
+	bmpSignature     = []byte("BM")
+)
+
+// DetectFormat identifies the image format of the file from its magic bytes,
+// ignoring the extension, and checks that the image header can be decoded.
+func DetectFormat(path string) (Format, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer file.Close()
+
+	return DetectFormatReader(file)
+}
+
+// DetectFormatReader is DetectFormat for an image read from r.
+func DetectFormatReader(r io.ReadSeeker) (Format, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading header: %w", err)
+	}
+	head = head[:n]
+
+	format, err := sniffFormat(head)
+	if err != nil {
+		return "", err
+	}
+
+	// PDFs are validated when their pages are counted
+	if format == FormatPDF {
+		return format, nil
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("rewinding: %w", err)
+	}
+	if _, _, err := image.DecodeConfig(r); err != nil {
+		return "", fmt.Errorf("corrupt %s image: %w", format, err)
+	}
+	return format, nil
+}
+
+func sniffFormat(head []byte) (Format, error) {
+	switch {
+	case bytes.HasPrefix(head, tiffLittleEndian), bytes.HasPrefix(head, tiffBigEndian):
+		return FormatTIFF, nil
+	case bytes.HasPrefix(head, bmpSignature):
+		return FormatBMP, nil
+	case pdf.Signature(head):
+		return FormatPDF, nil
+	}
+
+	switch contentType := http.DetectContentType(head); contentType {
+	case "image/jpeg":
+		return FormatJPEG, nil
+	case "image/png":
+		return FormatPNG, nil
+	case "image/bmp":
+		return FormatBMP, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, contentType)
+	}
+}
@@ -0,0 +1,75 @@
+package image
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestDetectFormat(t *testing.T) {
+	// Arrange
+	tempDir := t.TempDir()
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+
+	var pngBytes bytes.Buffer
+	if err := png.Encode(&pngBytes, img); err != nil {
+		t.Fatalf("encoding PNG failed: %v", err)
+	}
+	var tiffBytes bytes.Buffer
+	if err := imaging.Encode(&tiffBytes, img, imaging.TIFF); err != nil {
+		t.Fatalf("encoding TIFF failed: %v", err)
+	}
+	var bmpBytes bytes.Buffer
+	if err := imaging.Encode(&bmpBytes, img, imaging.BMP); err != nil {
+		t.Fatalf("encoding BMP failed: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		content     []byte
+		expected    Format
+		unsupported bool
+	}{
+		{name: "png_without_extension", content: pngBytes.Bytes(), expected: FormatPNG},
+		{name: "tiff_named.jpg", content: tiffBytes.Bytes(), expected: FormatTIFF},
+		{name: "scan.bmp", content: bmpBytes.Bytes(), expected: FormatBMP},
+		{name: "scan.pdf", content: []byte("%PDF-1.7\n1 0 obj\n<< >>\nendobj\n"), expected: FormatPDF},
+		{name: "notes.png", content: []byte("just some text"), unsupported: true},
+		{name: "truncated.png", content: pngBytes.Bytes()[:12]},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, tc.name)
+			if err := os.WriteFile(path, tc.content, 0644); err != nil {
+				t.Fatalf("writing file failed: %v", err)
+			}
+
+			// Act
+			actual, err := DetectFormat(path)
+
+			// Assert
+			if tc.expected == "" {
+				if err == nil {
+					t.Fatalf("expected error, got format %q", actual)
+				}
+				if tc.unsupported != errors.Is(err, ErrUnsupportedFormat) {
+					t.Errorf("unexpected error classification: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -1,46 +1,110 @@
 package image
 
 import (
+	"bytes"
 	"fmt"
-	"os"
-	"path/filepath"
+	"image"
+	"io"
+
+	"ocr-tool/internal/pdf"
 
 	"github.com/disintegration/imaging"
 )
 
-type ImageProcessor struct{}
+type ImageProcessor struct {
+	chain Chain
+	trace bool
+}
+
+// NewImageProcessor returns a processor applying chain, or DefaultChain when
+// chain is nil.
+func NewImageProcessor(chain Chain) *ImageProcessor {
+	if chain == nil {
+		chain = DefaultChain()
+	}
+	return &ImageProcessor{chain: chain}
+}
+
+// TraceSteps makes EnhanceQuality keep the decoded source and the output of
+// every step, see WriteDebugImages.
+func (ip *ImageProcessor) TraceSteps() {
+	ip.trace = true
+}
 
-func NewImageProcessor() *ImageProcessor {
-	return &ImageProcessor{}
+// Enhanced is a preprocessed image, PNG encoded unless the chain was empty.
+type Enhanced struct {
+	Data        []byte
+	Rotation    float64 // degrees counter-clockwise applied to turn the image upright
+	Orientation int     // EXIF orientation of the source
+	Steps       []StepTrace
+	Source      image.Image // decoded input, only kept by TraceSteps
 }
 
-func (ip *ImageProcessor) EnhanceQuality(path string) (string, error) {
-	img, err := imaging.Open(path)
+// EnhanceQuality runs the preprocessing chain on the 1-based page of a
+// multi-page TIFF or PDF read from r, or the whole image when page is 0.
+// Single images pass through untouched when the chain is empty.
+func (ip *ImageProcessor) EnhanceQuality(r io.Reader, page int) (Enhanced, error) {
+	content, err := io.ReadAll(r)
 	if err != nil {
-		return "", fmt.Errorf("opening image %s: %w", path, err)
+		return Enhanced{}, fmt.Errorf("reading image: %w", err)
+	}
+	if len(ip.chain) == 0 && page == 0 {
+		return Enhanced{Data: content}, nil
 	}
 
-	// Resize if too small
-	bounds := img.Bounds()
-	if bounds.Dx() < 300 || bounds.Dy() < 300 {
-		img = imaging.Resize(img, bounds.Dx()*2, bounds.Dy()*2, imaging.Lanczos)
+	img, err := DecodePage(content, page)
+	if err != nil {
+		return Enhanced{}, fmt.Errorf("opening image: %w", err)
+	}
+	orientation := 1
+	if page == 0 {
+		orientation = Orientation(content)
 	}
+	return ip.EnhanceImage(img, orientation)
+}
 
-	gray := imaging.Grayscale(img)
-	contrast := imaging.AdjustContrast(gray, 10)
-	sharp := imaging.Sharpen(contrast, 1.1)
+// EnhanceImage runs the preprocessing chain on an image already decoded, such
+// as a page of a PDF parsed once for all its pages. orientation is the EXIF
+// orientation of the source, 1 when unknown.
+func (ip *ImageProcessor) EnhanceImage(img image.Image, orientation int) (Enhanced, error) {
+	source := img
+	apply := ip.chain.Apply
+	if ip.trace {
+		apply = ip.chain.Trace
+	}
+	img, applied, err := apply(img, orientation)
+	if err != nil {
+		return Enhanced{}, err
+	}
 
-	extension := filepath.Ext(path)
-	localPath := path[:len(path)-len(extension)]
-	tempPath := localPath + "_processed" + extension
-	if err := imaging.Save(sharp, tempPath); err != nil {
-		// if err := imaging.Save(img, tempPath); err != nil {
-		return "", fmt.Errorf("saving processed image: %w", err)
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, imaging.PNG); err != nil {
+		return Enhanced{}, fmt.Errorf("encoding processed image: %w", err)
+	}
+	enhanced := Enhanced{Data: buf.Bytes(), Rotation: applied.Rotation, Orientation: orientation, Steps: applied.Steps}
+	if ip.trace {
+		enhanced.Source = source
 	}
+	return enhanced, nil
+}
 
-	return tempPath, nil
+// DecodePage decodes the 1-based page of a multi-page TIFF or the largest
+// image on a PDF page, or the whole image when page is 0.
+func DecodePage(content []byte, page int) (image.Image, error) {
+	switch {
+	case page == 0:
+		return imaging.Decode(bytes.NewReader(content))
+	case pdf.Signature(content):
+		return openPDFPage(content, page)
+	default:
+		return DecodeTIFFPage(content, page)
+	}
 }
 
-func (ip *ImageProcessor) Cleanup(filePath string) error {
-	return os.Remove(filePath)
+func openPDFPage(content []byte, page int) (image.Image, error) {
+	doc, err := pdf.Open(content)
+	if err != nil {
+		return nil, err
+	}
+	return doc.PageImage(page)
 }
@@ -0,0 +1,105 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+const tagOrientation = 0x0112
+
+// Orientation returns the EXIF orientation (1-8) stored in a JPEG or TIFF
+// image, or 1 when there is none.
+func Orientation(content []byte) int {
+	switch {
+	case bytes.HasPrefix(content, tiffLittleEndian), bytes.HasPrefix(content, tiffBigEndian):
+		return tiffOrientation(content)
+	case bytes.HasPrefix(content, []byte{0xff, 0xd8}):
+		if exif := jpegExif(content); exif != nil {
+			return tiffOrientation(exif)
+		}
+	}
+	return 1
+}
+
+// jpegExif returns the TIFF structure held by the Exif APP1 segment.
+func jpegExif(content []byte) []byte {
+	pos := 2
+	for pos+4 <= len(content) && content[pos] == 0xff {
+		marker := content[pos+1]
+		if marker == 0xda || marker == 0xd9 {
+			// Start of scan, no metadata follows
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(content[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(content) {
+			return nil
+		}
+		segment := content[pos+4 : end]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+		pos = end
+	}
+	return nil
+}
+
+// tiffOrientation reads the orientation tag of the first IFD.
+func tiffOrientation(data []byte) int {
+	if len(data) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if data[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	ifd := int(order.Uint32(data[4:]))
+	if ifd+2 > len(data) {
+		return 1
+	}
+	count := int(order.Uint16(data[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(data) {
+			break
+		}
+		if order.Uint16(data[entry:]) == tagOrientation {
+			if value := int(order.Uint16(data[entry+8:])); value >= 1 && value <= 8 {
+				return value
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// orient turns the image upright according to its EXIF orientation.
+func orient(img image.Image, _ map[string]float64, st *chainState) (image.Image, error) {
+	switch st.orientation {
+	case 2:
+		return imaging.FlipH(img), nil
+	case 3:
+		st.rotate(180)
+		return imaging.Rotate180(img), nil
+	case 4:
+		st.rotate(180)
+		return imaging.FlipV(img), nil
+	case 5:
+		st.rotate(90)
+		return imaging.Transpose(img), nil
+	case 6:
+		st.rotate(270)
+		return imaging.Rotate270(img), nil
+	case 7:
+		st.rotate(270)
+		return imaging.Transverse(img), nil
+	case 8:
+		st.rotate(90)
+		return imaging.Rotate90(img), nil
+	}
+	return img, nil
+}
@@ -0,0 +1,544 @@
+package image
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// Step is one named preprocessing operation with its numeric parameters. In
+// JSON a step is a flat object, {"step": "contrast", "amount": 10}.
+type Step struct {
+	Name   string
+	Params map[string]float64
+}
+
+// Chain is the ordered list of steps applied to every image before OCR. An
+// empty, non-nil chain hands the image to the engine unchanged.
+type Chain []Step
+
+// stepKind describes the parameters accepted by a step. The primary
+// parameter may be given without a name, as in "contrast:10". check rejects
+// out of range values, before any image is read when the chain is
+// validated.
+type stepKind struct {
+	params   []string
+	primary  string
+	defaults map[string]float64
+	check    func(p map[string]float64) error
+	apply    func(img image.Image, p map[string]float64, st *chainState) (image.Image, error)
+}
+
+// Bounds of the parameters that set the memory or CPU a step takes per
+// image. The output of resize depends on the input, so its pixel budget is
+// also checked when the step runs.
+const (
+	maxImageSide       = 1 << 14 // width or height asked of resize
+	maxImagePixels     = maxImageSide * maxImageSide
+	maxResizeScale     = 16
+	maxDespeckleRadius = 10   // median of a 21x21 window per pixel
+	maxBlobSize        = 1000 // pixels
+)
+
+var stepKinds = map[string]stepKind{
+	"resize": {
+		params:  []string{"scale", "width", "height", "min"},
+		primary: "scale",
+		check:   checkResize,
+		apply:   resize,
+	},
+	"grayscale": {
+		apply: func(img image.Image, _ map[string]float64, _ *chainState) (image.Image, error) {
+			return imaging.Grayscale(img), nil
+		},
+	},
+	"contrast": {
+		params:  []string{"amount"},
+		primary: "amount",
+		check: func(p map[string]float64) error {
+			if p["amount"] < -100 || p["amount"] > 100 {
+				return fmt.Errorf("amount must be between -100 and 100")
+			}
+			return nil
+		},
+		apply: func(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+			return imaging.AdjustContrast(img, p["amount"]), nil
+		},
+	},
+	"gamma": {
+		params:   []string{"gamma"},
+		primary:  "gamma",
+		defaults: map[string]float64{"gamma": 1},
+		check:    positive("gamma"),
+		apply: func(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+			return imaging.AdjustGamma(img, p["gamma"]), nil
+		},
+	},
+	"sharpen": {
+		params:   []string{"sigma"},
+		primary:  "sigma",
+		defaults: map[string]float64{"sigma": 1},
+		check:    positive("sigma"),
+		apply: func(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+			return imaging.Sharpen(img, p["sigma"]), nil
+		},
+	},
+	"blur": {
+		params:   []string{"sigma"},
+		primary:  "sigma",
+		defaults: map[string]float64{"sigma": 1},
+		check:    positive("sigma"),
+		apply: func(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+			return imaging.Blur(img, p["sigma"]), nil
+		},
+	},
+	"invert": {
+		apply: func(img image.Image, _ map[string]float64, _ *chainState) (image.Image, error) {
+			return imaging.Invert(img), nil
+		},
+	},
+	"threshold": {
+		params:   []string{"level"},
+		primary:  "level",
+		defaults: map[string]float64{"level": 128},
+		check: func(p map[string]float64) error {
+			if p["level"] < 0 || p["level"] > 255 {
+				return fmt.Errorf("level must be between 0 and 255")
+			}
+			return nil
+		},
+		apply: threshold,
+	},
+	"otsu": {
+		apply: func(img image.Image, _ map[string]float64, _ *chainState) (image.Image, error) {
+			return Otsu(img), nil
+		},
+	},
+	"sauvola": {
+		params:   []string{"window", "k", "r"},
+		primary:  "window",
+		defaults: map[string]float64{"window": 31, "k": 0.2, "r": 128},
+		check:    checkSauvola,
+		apply:    sauvolaStep,
+	},
+	"niblack": {
+		params:   []string{"window", "k"},
+		primary:  "window",
+		defaults: map[string]float64{"window": 31, "k": -0.2},
+		check:    checkWindow,
+		apply:    niblackStep,
+	},
+	"despeckle": {
+		params:   []string{"radius"},
+		primary:  "radius",
+		defaults: map[string]float64{"radius": 1},
+		check:    between("radius", 1, maxDespeckleRadius),
+		apply:    despeckleStep,
+	},
+	"remove_blobs": {
+		params:   []string{"max_size"},
+		primary:  "max_size",
+		defaults: map[string]float64{"max_size": 8},
+		check:    between("max_size", 1, maxBlobSize),
+		apply:    removeBlobsStep,
+	},
+	"crop_borders": {
+		params:   []string{"dark", "limit"},
+		defaults: map[string]float64{"dark": 0.5, "limit": 0.2},
+		check:    checkCropBorders,
+		apply:    cropBordersStep,
+	},
+	"crop": {
+		params: []string{"left", "top", "right", "bottom"},
+		check: func(p map[string]float64) error {
+			if p["left"] < 0 || p["top"] < 0 || p["right"] < 0 || p["bottom"] < 0 {
+				return fmt.Errorf("margins must not be negative")
+			}
+			return nil
+		},
+		apply: crop,
+	},
+	"rotate": {
+		params:  []string{"angle"},
+		primary: "angle",
+		apply: func(img image.Image, p map[string]float64, st *chainState) (image.Image, error) {
+			st.rotate(p["angle"])
+			return imaging.Rotate(img, p["angle"], color.White), nil
+		},
+	},
+	"orient": {
+		apply: orient,
+	},
+	"deskew": {
+		params:   []string{"max_angle", "min_angle"},
+		primary:  "max_angle",
+		defaults: map[string]float64{"max_angle": 10, "min_angle": 0.2},
+		check: func(p map[string]float64) error {
+			if p["max_angle"] <= 0 || p["max_angle"] > 45 || p["min_angle"] < 0 {
+				return fmt.Errorf("max_angle must be in (0, 45] and min_angle not negative")
+			}
+			return nil
+		},
+		apply: deskew,
+	},
+}
+
+// DefaultChain reproduces the historical enhancement: upscale images under
+// 300px, then grayscale, contrast +10 and sharpen 1.1. It does not rotate,
+// orient and deskew are opt-in.
+func DefaultChain() Chain {
+	return Chain{
+		{Name: "resize", Params: map[string]float64{"min": 300, "scale": 2}},
+		{Name: "grayscale"},
+		{Name: "contrast", Params: map[string]float64{"amount": 10}},
+		{Name: "sharpen", Params: map[string]float64{"sigma": 1.1}},
+	}
+}
+
+// ParseChain parses the CLI form of a chain: "none", "default", or steps
+// separated by commas with colon separated parameters, for example
+// "resize:min=300:scale=2,grayscale,contrast:10".
+func ParseChain(spec string) (Chain, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "none":
+		return Chain{}, nil
+	case "default", "":
+		return DefaultChain(), nil
+	}
+
+	chain := Chain{}
+	for _, field := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(field), ":")
+		step := Step{Name: strings.ToLower(parts[0])}
+		kind, ok := stepKinds[step.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown preprocessing step %q", parts[0])
+		}
+
+		for _, param := range parts[1:] {
+			key, value, named := strings.Cut(param, "=")
+			if !named {
+				key, value = kind.primary, param
+			}
+			number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("step %s: invalid value %q", step.Name, value)
+			}
+			if step.Params == nil {
+				step.Params = make(map[string]float64)
+			}
+			step.Params[strings.TrimSpace(key)] = number
+		}
+		chain = append(chain, step)
+	}
+	return chain, chain.Validate()
+}
+
+// LoadChain reads a chain from a JSON file holding either a list of steps or
+// a string in the CLI form.
+func LoadChain(path string) (Chain, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var chain Chain
+	if err := json.Unmarshal(content, &chain); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return chain, nil
+}
+
+// Validate reports unknown steps and parameters, and parameter values out of
+// range.
+func (c Chain) Validate() error {
+	var errs []error
+	for i, step := range c {
+		kind, ok := stepKinds[step.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("step %d: unknown preprocessing step %q", i+1, step.Name))
+			continue
+		}
+		known := true
+		for key := range step.Params {
+			if !slices.Contains(kind.params, key) {
+				errs = append(errs, fmt.Errorf("step %d (%s): unknown parameter %q", i+1, step.Name, key))
+				known = false
+			}
+		}
+		if known && kind.check != nil {
+			if err := kind.check(kind.with(step.Params)); err != nil {
+				errs = append(errs, fmt.Errorf("step %d (%s): %w", i+1, step.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// with returns the parameters of a step with the defaults of its kind.
+func (k stepKind) with(params map[string]float64) map[string]float64 {
+	effective := make(map[string]float64, len(k.defaults)+len(params))
+	for key, value := range k.defaults {
+		effective[key] = value
+	}
+	for key, value := range params {
+		effective[key] = value
+	}
+	return effective
+}
+
+// positive checks that the named parameter is above zero.
+func positive(name string) func(p map[string]float64) error {
+	return func(p map[string]float64) error {
+		if p[name] <= 0 {
+			return fmt.Errorf("%s must be positive", name)
+		}
+		return nil
+	}
+}
+
+// between checks that the named parameter is within [low, high].
+func between(name string, low, high float64) func(p map[string]float64) error {
+	return func(p map[string]float64) error {
+		if p[name] < low || p[name] > high {
+			return fmt.Errorf("%s must be between %g and %g", name, low, high)
+		}
+		return nil
+	}
+}
+
+// Applied records what a chain did to an image.
+type Applied struct {
+	Rotation float64 // degrees counter-clockwise, in [0, 360)
+	Steps    []StepTrace
+}
+
+// StepTrace records one step run by a chain.
+type StepTrace struct {
+	Name     string
+	Params   map[string]float64 // effective parameters, defaults included
+	Duration time.Duration
+	Image    image.Image // output of the step, only kept by Trace
+}
+
+// chainState is shared by the steps of one Apply call.
+type chainState struct {
+	orientation int // EXIF orientation of the source, 1 when unknown
+	applied     Applied
+}
+
+func (st *chainState) rotate(angle float64) {
+	rotation := math.Mod(st.applied.Rotation+angle, 360)
+	if rotation < 0 {
+		rotation += 360
+	}
+	st.applied.Rotation = math.Round(rotation*100) / 100
+}
+
+// Apply runs the steps in order. orientation is the EXIF orientation of the
+// source image, used by the orient step; pass 1 when unknown.
+func (c Chain) Apply(img image.Image, orientation int) (image.Image, Applied, error) {
+	return c.apply(img, orientation, false)
+}
+
+// Trace is Apply keeping the output of every step in Applied.Steps.
+func (c Chain) Trace(img image.Image, orientation int) (image.Image, Applied, error) {
+	return c.apply(img, orientation, true)
+}
+
+func (c Chain) apply(img image.Image, orientation int, keep bool) (image.Image, Applied, error) {
+	st := &chainState{orientation: orientation}
+	for i, step := range c {
+		kind, ok := stepKinds[step.Name]
+		if !ok {
+			return nil, st.applied, fmt.Errorf("step %d: unknown preprocessing step %q", i+1, step.Name)
+		}
+
+		params := kind.with(step.Params)
+		if kind.check != nil {
+			if err := kind.check(params); err != nil {
+				return nil, st.applied, fmt.Errorf("step %d (%s): %w", i+1, step.Name, err)
+			}
+		}
+
+		start := time.Now()
+		var err error
+		if img, err = kind.apply(img, params, st); err != nil {
+			return nil, st.applied, fmt.Errorf("step %d (%s): %w", i+1, step.Name, err)
+		}
+
+		trace := StepTrace{Name: step.Name, Params: params, Duration: time.Since(start)}
+		if keep {
+			trace.Image = img
+		}
+		st.applied.Steps = append(st.applied.Steps, trace)
+	}
+	return img, st.applied, nil
+}
+
+// String returns the chain in the CLI form accepted by ParseChain.
+func (c Chain) String() string {
+	if len(c) == 0 {
+		return "none"
+	}
+
+	fields := make([]string, len(c))
+	for i, step := range c {
+		parts := []string{step.Name}
+		keys := make([]string, 0, len(step.Params))
+		for key := range step.Params {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			parts = append(parts, key+"="+strconv.FormatFloat(step.Params[key], 'g', -1, 64))
+		}
+		fields[i] = strings.Join(parts, ":")
+	}
+	return strings.Join(fields, ",")
+}
+
+func (c *Chain) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var spec string
+	if err := json.Unmarshal(data, &spec); err == nil {
+		chain, err := ParseChain(spec)
+		if err != nil {
+			return err
+		}
+		*c = chain
+		return nil
+	}
+
+	var steps []Step
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return err
+	}
+	chain := Chain(steps)
+	if chain == nil {
+		chain = Chain{}
+	}
+	if err := chain.Validate(); err != nil {
+		return err
+	}
+	*c = chain
+	return nil
+}
+
+func (s Step) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]any, len(s.Params)+1)
+	for key, value := range s.Params {
+		fields[key] = value
+	}
+	fields["step"] = s.Name
+	return json.Marshal(fields)
+}
+
+func (s *Step) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var name string
+	if err := json.Unmarshal(fields["step"], &name); err != nil || name == "" {
+		return fmt.Errorf(`preprocessing step needs a "step" name`)
+	}
+	delete(fields, "step")
+
+	s.Name = strings.ToLower(name)
+	s.Params = nil
+	for key, raw := range fields {
+		var value float64
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return fmt.Errorf("step %s: parameter %q must be a number", s.Name, key)
+		}
+		if s.Params == nil {
+			s.Params = make(map[string]float64)
+		}
+		s.Params[key] = value
+	}
+	return nil
+}
+
+func checkResize(p map[string]float64) error {
+	if p["scale"] < 0 || p["width"] < 0 || p["height"] < 0 || p["min"] < 0 {
+		return fmt.Errorf("scale, width, height and min must not be negative")
+	}
+	if p["scale"] == 0 && p["width"] == 0 && p["height"] == 0 {
+		return fmt.Errorf("needs a positive scale, width or height")
+	}
+	if p["scale"] > maxResizeScale {
+		return fmt.Errorf("scale must be at most %d", maxResizeScale)
+	}
+	if p["width"] > maxImageSide || p["height"] > maxImageSide {
+		return fmt.Errorf("width and height must be at most %d", maxImageSide)
+	}
+	return nil
+}
+
+// resize scales by "scale", or to "width" and/or "height" keeping the aspect
+// ratio when one is 0. With "min" the image is only resized when one of its
+// sides is shorter than min pixels.
+func resize(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+	bounds := img.Bounds()
+	if minSide := p["min"]; minSide > 0 && float64(bounds.Dx()) >= minSide && float64(bounds.Dy()) >= minSide {
+		return img, nil
+	}
+
+	width, height := int(p["width"]), int(p["height"])
+	if scale := p["scale"]; scale > 0 {
+		width = int(math.Round(float64(bounds.Dx()) * scale))
+		height = int(math.Round(float64(bounds.Dy()) * scale))
+	}
+	if width < 0 || height < 0 || (width == 0 && height == 0) {
+		return nil, fmt.Errorf("needs a positive scale, width or height")
+	}
+	// A single side keeps the aspect ratio, as imaging.Resize does
+	outWidth, outHeight := float64(width), float64(height)
+	if width == 0 {
+		outWidth = math.Round(float64(bounds.Dx()) * outHeight / float64(bounds.Dy()))
+	}
+	if height == 0 {
+		outHeight = math.Round(float64(bounds.Dy()) * outWidth / float64(bounds.Dx()))
+	}
+	if outWidth*outHeight > maxImagePixels {
+		return nil, fmt.Errorf("resized image of %.0fx%.0f exceeds %d pixels", outWidth, outHeight, maxImagePixels)
+	}
+	return imaging.Resize(img, width, height, imaging.Lanczos), nil
+}
+
+// threshold binarizes the image: pixels at or above level become white.
+func threshold(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+	level := p["level"]
+	// Pixels at the level count as white
+	return binarize(toGray(img), func(int, int) float64 { return level - 0.5 }), nil
+}
+
+// crop trims the given number of pixels from each edge.
+func crop(img image.Image, p map[string]float64, _ *chainState) (image.Image, error) {
+	bounds := img.Bounds()
+	rect := image.Rect(
+		bounds.Min.X+int(p["left"]),
+		bounds.Min.Y+int(p["top"]),
+		bounds.Max.X-int(p["right"]),
+		bounds.Max.Y-int(p["bottom"]),
+	)
+	if rect.Empty() {
+		return nil, fmt.Errorf("margins must leave a non-empty image")
+	}
+	return imaging.Crop(img, rect), nil
+}
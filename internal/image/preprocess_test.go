@@ -0,0 +1,167 @@
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestParseChain(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     string
+		expected string
+		invalid  bool
+	}{
+		{name: "none", spec: "none", expected: "none"},
+		{name: "default", spec: "default", expected: DefaultChain().String()},
+		{name: "primary parameter", spec: "grayscale, Contrast:10,threshold", expected: "grayscale,contrast:amount=10,threshold"},
+		{name: "named parameters", spec: "resize:min=300:scale=2,crop:left=5:bottom=5", expected: "resize:min=300:scale=2,crop:bottom=5:left=5"},
+		{name: "unknown step", spec: "grayscale,emboss", invalid: true},
+		{name: "unknown parameter", spec: "blur:radius=2", invalid: true},
+		{name: "step without parameters", spec: "invert:1", invalid: true},
+		{name: "not a number", spec: "gamma:bright", invalid: true},
+		{name: "negative scale", spec: "resize:-2", invalid: true},
+		{name: "scale too large", spec: "resize:1000", invalid: true},
+		{name: "width too large", spec: "resize:width=1000000000", invalid: true},
+		{name: "despeckle radius too large", spec: "despeckle:1000", invalid: true},
+		{name: "blobs too large", spec: "remove_blobs:1000000", invalid: true},
+		{name: "gamma not positive", spec: "gamma:0", invalid: true},
+		{name: "threshold out of range", spec: "threshold:300", invalid: true},
+		{name: "contrast out of range", spec: "contrast:150", invalid: true},
+		{name: "window too small", spec: "sauvola:window=2", invalid: true},
+		{name: "negative margin", spec: "crop:left=-5", invalid: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			chain, err := ParseChain(tc.spec)
+
+			// Assert
+			if tc.invalid {
+				if err == nil {
+					t.Fatalf("expected error, got chain %s", chain)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if chain.String() != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, chain.String())
+			}
+		})
+	}
+}
+
+func TestChain_UnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+		invalid  bool
+	}{
+		{name: "steps", input: `[{"step": "resize", "width": 1200}, {"step": "gamma", "gamma": 0.8}]`, expected: "resize:width=1200,gamma:gamma=0.8"},
+		{name: "string form", input: `"grayscale,sharpen:1.5"`, expected: "grayscale,sharpen:sigma=1.5"},
+		{name: "empty list", input: `[]`, expected: "none"},
+		{name: "missing name", input: `[{"amount": 10}]`, invalid: true},
+		{name: "unknown parameter", input: `[{"step": "rotate", "degrees": 90}]`, invalid: true},
+		{name: "value out of range", input: `[{"step": "despeckle", "radius": 0}]`, invalid: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			var chain Chain
+			err := json.Unmarshal([]byte(tc.input), &chain)
+
+			// Assert
+			if tc.invalid {
+				if err == nil {
+					t.Fatalf("expected error, got chain %s", chain)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if chain.String() != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, chain.String())
+			}
+		})
+	}
+}
+
+func TestChain_Apply(t *testing.T) {
+	// Arrange: a horizontal gradient from black to white
+	src := image.NewGray(image.Rect(0, 0, 256, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 256; x++ {
+			src.Pix[y*src.Stride+x] = uint8(x)
+		}
+	}
+	chain, err := ParseChain("crop:left=6:right=50,resize:min=100:scale=2,threshold:100")
+	if err != nil {
+		t.Fatalf("parsing chain failed: %v", err)
+	}
+
+	// Act
+	out, _, err := chain.Apply(src, 1)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("applying chain failed: %v", err)
+	}
+	if out.Bounds() != image.Rect(0, 0, 400, 20) {
+		t.Fatalf("expected 400x20 image, got %v", out.Bounds())
+	}
+	gray := out.(*image.Gray)
+	for _, px := range gray.Pix {
+		if px != 0 && px != 0xff {
+			t.Fatalf("expected a binary image, found value %d", px)
+		}
+	}
+	if gray.Pix[0] != 0 || gray.Pix[399] != 0xff {
+		t.Errorf("expected black left edge and white right edge, got %d and %d", gray.Pix[0], gray.Pix[399])
+	}
+}
+
+func TestChain_Apply_ResizeTooLarge(t *testing.T) {
+	// Arrange: keeping the aspect ratio of a thin strip makes it 16384 x 1.6M
+	src := image.NewGray(image.Rect(0, 0, 1, 100))
+	chain, err := ParseChain("resize:width=16384")
+	if err != nil {
+		t.Fatalf("parsing chain failed: %v", err)
+	}
+
+	// Act
+	_, _, err = chain.Apply(src, 1)
+
+	// Assert
+	if err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
+
+func TestEnhanceQuality_NoneKeepsBytes(t *testing.T) {
+	// Arrange
+	var pngBytes bytes.Buffer
+	if err := png.Encode(&pngBytes, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encoding PNG failed: %v", err)
+	}
+	processor := NewImageProcessor(Chain{})
+
+	// Act
+	out, err := processor.EnhanceQuality(bytes.NewReader(pngBytes.Bytes()), 0)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(out.Data, pngBytes.Bytes()) {
+		t.Error("expected the raw image to be passed through")
+	}
+}
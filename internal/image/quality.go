@@ -0,0 +1,153 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// qualitySide is the longest side images are measured at, so that the
+// sharpness of a 12 megapixel photo and of a 300 dpi scan are comparable.
+const qualitySide = 1000
+
+// Reasons an image is rejected before OCR.
+const (
+	ReasonTooBlurry  = "too_blurry"
+	ReasonBlankPage  = "blank_page"
+	ReasonBlackFrame = "black_frame"
+)
+
+// ErrLowQuality matches every QualityError with errors.Is.
+var ErrLowQuality = errors.New("image quality too low for OCR")
+
+// Quality holds the measurements of an image, all on its luminance.
+type Quality struct {
+	Sharpness  float64 `json:"sharpness"`  // variance of the Laplacian, low when blurry
+	Brightness float64 `json:"brightness"` // mean from 0 to 255
+	Contrast   float64 `json:"contrast"`   // standard deviation from 0 to 127.5
+	Ink        float64 `json:"ink"`        // fraction of pixels darker than mid-gray
+}
+
+// QualityThresholds rejects images before OCR. A zero field disables its
+// check.
+type QualityThresholds struct {
+	MinSharpness  float64 `json:"min_sharpness"`  // below is too_blurry
+	MinContrast   float64 `json:"min_contrast"`   // below is blank_page
+	MinInk        float64 `json:"min_ink"`        // below is blank_page
+	MaxInk        float64 `json:"max_ink"`        // above is black_frame
+	MinBrightness float64 `json:"min_brightness"` // below is black_frame
+}
+
+// QualityError reports why an image was rejected.
+type QualityError struct {
+	Reason  string
+	Detail  string
+	Quality Quality
+}
+
+func (e *QualityError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Detail)
+}
+
+func (e *QualityError) Is(target error) bool {
+	return target == ErrLowQuality
+}
+
+// Enabled reports whether any check is configured.
+func (t QualityThresholds) Enabled() bool {
+	return t != QualityThresholds{}
+}
+
+// Check returns a *QualityError for the first threshold q fails. Black
+// frames are checked before blank pages since they have no contrast either.
+func (t QualityThresholds) Check(q Quality) error {
+	reject := func(reason, format string, args ...any) error {
+		return &QualityError{Reason: reason, Detail: fmt.Sprintf(format, args...), Quality: q}
+	}
+
+	switch {
+	case t.MaxInk > 0 && q.Ink > t.MaxInk:
+		return reject(ReasonBlackFrame, "ink coverage %.3f above %.3f", q.Ink, t.MaxInk)
+	case t.MinBrightness > 0 && q.Brightness < t.MinBrightness:
+		return reject(ReasonBlackFrame, "brightness %.1f below %.1f", q.Brightness, t.MinBrightness)
+	case t.MinInk > 0 && q.Ink < t.MinInk:
+		return reject(ReasonBlankPage, "ink coverage %.4f below %.4f", q.Ink, t.MinInk)
+	case t.MinContrast > 0 && q.Contrast < t.MinContrast:
+		return reject(ReasonBlankPage, "contrast %.1f below %.1f", q.Contrast, t.MinContrast)
+	case t.MinSharpness > 0 && q.Sharpness < t.MinSharpness:
+		return reject(ReasonTooBlurry, "sharpness %.1f below %.1f", q.Sharpness, t.MinSharpness)
+	}
+	return nil
+}
+
+// Validate rejects negative thresholds and ink fractions above 1.
+func (t QualityThresholds) Validate() error {
+	if t.MinSharpness < 0 || t.MinContrast < 0 || t.MinInk < 0 || t.MaxInk < 0 || t.MinBrightness < 0 {
+		return fmt.Errorf("quality thresholds must not be negative")
+	}
+	if t.MinInk > 1 || t.MaxInk > 1 {
+		return fmt.Errorf("ink thresholds are fractions from 0 to 1")
+	}
+	return nil
+}
+
+// AssessQuality measures img, downscaled so its longest side is at most
+// qualitySide pixels.
+func AssessQuality(img image.Image) Quality {
+	bounds := img.Bounds()
+	if bounds.Dx() > qualitySide || bounds.Dy() > qualitySide {
+		img = imaging.Fit(img, qualitySide, qualitySide, imaging.Box)
+	}
+	gray := toGray(img)
+	bounds = gray.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	var q Quality
+	if w == 0 || h == 0 {
+		return q
+	}
+
+	var sum, squares float64
+	var ink int
+	for y := 0; y < h; y++ {
+		for _, v := range gray.Pix[y*gray.Stride : y*gray.Stride+w] {
+			sum += float64(v)
+			squares += float64(v) * float64(v)
+			if v < inkLevel {
+				ink++
+			}
+		}
+	}
+	n := float64(w * h)
+	q.Brightness = sum / n
+	q.Contrast = math.Sqrt(max(squares/n-q.Brightness*q.Brightness, 0))
+	q.Ink = float64(ink) / n
+	q.Sharpness = laplacianVariance(gray)
+	return q
+}
+
+// laplacianVariance convolves the inner pixels with the 4-neighbour
+// Laplacian kernel and returns the variance of the response. Sharp edges give
+// strong responses, blur flattens them.
+func laplacianVariance(gray *image.Gray) float64 {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	if w < 3 || h < 3 {
+		return 0
+	}
+
+	var sum, squares float64
+	at := func(x, y int) float64 { return float64(gray.Pix[y*gray.Stride+x]) }
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			v := at(x-1, y) + at(x+1, y) + at(x, y-1) + at(x, y+1) - 4*at(x, y)
+			sum += v
+			squares += v * v
+		}
+	}
+	n := float64((w - 2) * (h - 2))
+	mean := sum / n
+	return squares/n - mean*mean
+}
@@ -0,0 +1,67 @@
+package image
+
+import (
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func uniformPage(v uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 60, 60))
+	for i := range img.Pix {
+		img.Pix[i] = v
+	}
+	return img
+}
+
+func TestQualityThresholds_Check(t *testing.T) {
+	thresholds := QualityThresholds{MinSharpness: 100, MinContrast: 5, MinInk: 0.001, MaxInk: 0.95}
+
+	testCases := []struct {
+		name     string
+		img      image.Image
+		expected string
+	}{
+		{name: "text passes", img: speckledPage(), expected: ""},
+		{name: "white page", img: uniformPage(0xff), expected: ReasonBlankPage},
+		{name: "black frame", img: uniformPage(0), expected: ReasonBlackFrame},
+		{name: "blurred text", img: imaging.Blur(speckledPage(), 4), expected: ReasonTooBlurry},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			err := thresholds.Check(AssessQuality(tc.img))
+
+			// Assert
+			if tc.expected == "" {
+				if err != nil {
+					t.Fatalf("expected the image to pass, got %v", err)
+				}
+				return
+			}
+			var qualityErr *QualityError
+			if !errors.As(err, &qualityErr) || qualityErr.Reason != tc.expected {
+				t.Fatalf("expected %s, got %v", tc.expected, err)
+			}
+			if !errors.Is(err, ErrLowQuality) {
+				t.Errorf("expected %v to match ErrLowQuality", err)
+			}
+		})
+	}
+}
+
+func TestQualityThresholds_ZeroDisablesChecks(t *testing.T) {
+	// Arrange
+	var thresholds QualityThresholds
+
+	// Act
+	err := thresholds.Check(AssessQuality(uniformPage(0xff)))
+
+	// Assert
+	if thresholds.Enabled() || err != nil {
+		t.Errorf("expected zero thresholds to accept everything, got enabled=%v err=%v", thresholds.Enabled(), err)
+	}
+}
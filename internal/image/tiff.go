@@ -0,0 +1,117 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+
+	"golang.org/x/image/tiff"
+)
+
+// maxTIFFPages guards against IFD chains that loop or are absurdly long.
+const maxTIFFPages = 10000
+
+var errNotTIFF = errors.New("not a TIFF file")
+
+// TIFFPageCount walks the IFD chain of a TIFF file and returns the number of
+// pages (images) it holds.
+func TIFFPageCount(r io.ReaderAt) (int, error) {
+	offsets, err := tiffPageOffsets(r)
+	if err != nil {
+		return 0, err
+	}
+	return len(offsets), nil
+}
+
+// DecodeTIFFPage decodes the 1-based page of a multi-page TIFF. The decoder
+// only ever reads the first IFD, so it is handed a view of data whose header
+// points at the requested one instead.
+func DecodeTIFFPage(data []byte, page int) (image.Image, error) {
+	offsets, err := tiffPageOffsets(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 || page > len(offsets) {
+		return nil, fmt.Errorf("page %d out of range (1-%d)", page, len(offsets))
+	}
+
+	view := &tiffPageReader{data: data}
+	copy(view.header[:], data[:8])
+	tiffByteOrder(data).PutUint32(view.header[4:8], offsets[page-1])
+
+	img, err := tiff.Decode(io.NewSectionReader(view, 0, int64(len(data))))
+	if err != nil {
+		return nil, fmt.Errorf("decoding TIFF page %d: %w", page, err)
+	}
+	return img, nil
+}
+
+// tiffPageReader reads data with its header replaced, so the pages of a
+// file share its bytes instead of each decoding a patched copy.
+type tiffPageReader struct {
+	data   []byte
+	header [8]byte
+}
+
+func (r *tiffPageReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[off:])
+	if off < int64(len(r.header)) {
+		copy(p[:n], r.header[off:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func tiffPageOffsets(r io.ReaderAt) ([]uint32, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("reading TIFF header: %w", err)
+	}
+	order := tiffByteOrder(header)
+	if order == nil || order.Uint16(header[2:4]) != 42 {
+		return nil, errNotTIFF
+	}
+
+	var offsets []uint32
+	seen := make(map[uint32]bool)
+	buf := make([]byte, 4)
+	for offset := order.Uint32(header[4:8]); offset != 0; {
+		if seen[offset] || len(offsets) >= maxTIFFPages {
+			return nil, fmt.Errorf("invalid TIFF IFD chain at offset %d", offset)
+		}
+		seen[offset] = true
+		offsets = append(offsets, offset)
+
+		if _, err := r.ReadAt(buf[:2], int64(offset)); err != nil {
+			return nil, fmt.Errorf("reading TIFF IFD at offset %d: %w", offset, err)
+		}
+		entries := int64(order.Uint16(buf[:2]))
+		if _, err := r.ReadAt(buf, int64(offset)+2+entries*12); err != nil {
+			return nil, fmt.Errorf("reading next TIFF IFD offset: %w", err)
+		}
+		offset = order.Uint32(buf)
+	}
+	return offsets, nil
+}
+
+func tiffByteOrder(header []byte) binary.ByteOrder {
+	switch {
+	case bytes.HasPrefix(header, []byte("II")):
+		return binary.LittleEndian
+	case bytes.HasPrefix(header, []byte("MM")):
+		return binary.BigEndian
+	default:
+		return nil
+	}
+}
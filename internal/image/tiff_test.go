@@ -0,0 +1,100 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeTIFFPage_MultiPage(t *testing.T) {
+	// Arrange - three 2x2 grayscale pages filled with 10, 20 and 30
+	data := buildGrayTIFF([]byte{10, 20, 30})
+	original := bytes.Clone(data)
+
+	// Act
+	count, err := TIFFPageCount(bytes.NewReader(data))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("counting pages failed: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 pages, got %d", count)
+	}
+
+	for page := 1; page <= count; page++ {
+		img, err := DecodeTIFFPage(data, page)
+		if err != nil {
+			t.Fatalf("decoding page %d failed: %v", page, err)
+		}
+		r, _, _, _ := img.At(1, 1).RGBA()
+		if expected := uint32(page*10) * 0x101; r != expected {
+			t.Errorf("page %d: expected gray %d, got %d", page, expected, r)
+		}
+	}
+
+	if _, err := DecodeTIFFPage(data, 4); err == nil {
+		t.Errorf("expected error for page out of range")
+	}
+	if !bytes.Equal(data, original) {
+		t.Errorf("decoding pages modified the shared file content")
+	}
+}
+
+func TestTIFFPageCount_RejectsLoop(t *testing.T) {
+	// Arrange - point the last IFD back at the first one
+	data := buildGrayTIFF([]byte{10, 20})
+	first := binary.LittleEndian.Uint32(data[4:8])
+	last := len(data) - 4
+	binary.LittleEndian.PutUint32(data[last:], first)
+
+	// Act
+	_, err := TIFFPageCount(bytes.NewReader(data))
+
+	// Assert
+	if err == nil {
+		t.Errorf("expected error for looping IFD chain")
+	}
+}
+
+// buildGrayTIFF writes an uncompressed little-endian TIFF with one 2x2 8-bit
+// grayscale page per value, chaining the IFDs in order.
+func buildGrayTIFF(values []byte) []byte {
+	const entries = 8
+	le := binary.LittleEndian
+	buf := []byte("II*\x00\x00\x00\x00\x00")
+	nextPtr := 4
+
+	for _, v := range values {
+		pixels := len(buf)
+		buf = append(buf, v, v, v, v)
+
+		ifd := len(buf)
+		le.PutUint32(buf[nextPtr:], uint32(ifd))
+
+		buf = le.AppendUint16(buf, entries)
+		tag := func(id, typ uint16, value uint32) {
+			buf = le.AppendUint16(buf, id)
+			buf = le.AppendUint16(buf, typ)
+			buf = le.AppendUint32(buf, 1)
+			if typ == 3 {
+				buf = le.AppendUint16(buf, uint16(value))
+				buf = le.AppendUint16(buf, 0)
+			} else {
+				buf = le.AppendUint32(buf, value)
+			}
+		}
+		tag(256, 3, 2)              // ImageWidth
+		tag(257, 3, 2)              // ImageLength
+		tag(258, 3, 8)              // BitsPerSample
+		tag(259, 3, 1)              // Compression: none
+		tag(262, 3, 1)              // PhotometricInterpretation: BlackIsZero
+		tag(273, 4, uint32(pixels)) // StripOffsets
+		tag(278, 3, 2)              // RowsPerStrip
+		tag(279, 4, 4)              // StripByteCounts
+
+		nextPtr = len(buf)
+		buf = le.AppendUint32(buf, 0)
+	}
+	return buf
+}
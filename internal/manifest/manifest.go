@@ -0,0 +1,196 @@
+package manifest
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"ocr-tool/internal/writer"
+)
+
+type Status string
+
+const (
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+	StatusTimeout Status = "timeout" // OCR ran out of time, retried on resume like failures
+	StatusWriting Status = "writing" // output being written or partly written, in doubt when it is the last entry
+)
+
+// Entry is one line of the JSON-lines journal. When a path appears more than
+// once the last entry wins.
+type Entry struct {
+	Path   string    `json:"path"`
+	Hash   string    `json:"hash"`
+	Status Status    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Manifest journals the outcome of every input of a run so that an
+// interrupted run can be resumed. A nil *Manifest is valid and records
+// nothing.
+type Manifest struct {
+	sink      *writer.NDJSONWriter[Entry]
+	mu        sync.Mutex
+	completed map[string]string // path -> hash of inputs done in previous runs
+	inDoubt   map[string]string // path -> hash of inputs whose output write was interrupted
+	tracked   map[string]string // path -> hash of inputs seen in this run
+}
+
+// Open creates the journal at path. With resume the existing journal is kept
+// and its completed entries are loaded; otherwise it is truncated.
+func Open(path string, resume bool) (*Manifest, error) {
+	m := &Manifest{
+		sink:      writer.NewNDJSONSink[Entry](path),
+		completed: make(map[string]string),
+		inDoubt:   make(map[string]string),
+		tracked:   make(map[string]string),
+	}
+
+	if !resume {
+		if err := m.sink.WriteToFile(nil, path, true); err != nil {
+			m.sink.Close()
+			return nil, fmt.Errorf("creating manifest %s: %w", path, err)
+		}
+		return m, nil
+	}
+
+	if err := m.load(path); err != nil {
+		m.sink.Close()
+		return nil, fmt.Errorf("loading manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (m *Manifest) load(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A torn last line from a crash is expected, the input is simply retried
+			continue
+		}
+		delete(m.completed, entry.Path)
+		delete(m.inDoubt, entry.Path)
+		switch entry.Status {
+		case StatusDone:
+			m.completed[entry.Path] = entry.Hash
+		case StatusWriting:
+			m.inDoubt[entry.Path] = entry.Hash
+		}
+	}
+	return scanner.Err()
+}
+
+// Track registers an input of the current run with its content hash.
+func (m *Manifest) Track(path, hash string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.tracked[path] = hash
+	m.mu.Unlock()
+}
+
+// Completed reports whether a previous run finished path with the same content.
+func (m *Manifest) Completed(path, hash string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	done, ok := m.completed[path]
+	return ok && done == hash
+}
+
+// InDoubt reports whether a previous run stopped while writing the output of
+// path, tracked with the same content in this run. Its record may or may not
+// have reached the outputs.
+func (m *Manifest) InDoubt(path string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hash, ok := m.inDoubt[path]
+	return ok && m.tracked[path] == hash
+}
+
+// Written reports whether a previous run wrote, or may have written, the
+// output of path, whatever its content. Such inputs must not be journaled
+// again without their hash, which would lose that entry.
+func (m *Manifest) Written(path string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, done := m.completed[path]
+	_, inDoubt := m.inDoubt[path]
+	return done || inDoubt
+}
+
+// Record appends the outcome of a tracked input to the journal. Untracked
+// paths, such as pipeline-level errors, are ignored.
+func (m *Manifest) Record(path string, status Status, cause error) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	hash, ok := m.tracked[path]
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	entry := Entry{Path: path, Hash: hash, Status: status, Time: time.Now().UTC()}
+	if cause != nil {
+		entry.Error = cause.Error()
+	}
+	return m.sink.Write([]Entry{entry})
+}
+
+func (m *Manifest) Close() error {
+	if m == nil {
+		return nil
+	}
+	return m.sink.Close()
+}
+
+// HashFile returns the hex encoded SHA-256 of the file content.
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return Hash(file)
+}
+
+// Hash returns the hex encoded SHA-256 of everything read from r.
+func Hash(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,136 @@
+package manifest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifest_ResumeSkipsOnlyCompleted(t *testing.T) {
+	// Arrange
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "run_manifest.jsonl")
+
+	first, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("opening manifest failed: %v", err)
+	}
+	first.Track("done.png", "hash-done")
+	first.Track("failed.png", "hash-failed")
+	first.Track("retried.png", "hash-retried")
+	first.Record("done.png", StatusDone, nil)
+	first.Record("failed.png", StatusFailed, errors.New("boom"))
+	first.Record("retried.png", StatusDone, nil)
+	first.Record("retried.png", StatusSkipped, errors.New("interrupted"))
+	first.Close()
+
+	// Act
+	resumed, err := Open(path, true)
+	if err != nil {
+		t.Fatalf("resuming manifest failed: %v", err)
+	}
+	defer resumed.Close()
+
+	// Assert
+	testCases := []struct {
+		path, hash string
+		expected   bool
+	}{
+		{"done.png", "hash-done", true},
+		{"done.png", "hash-changed", false},
+		{"failed.png", "hash-failed", false},
+		{"retried.png", "hash-retried", false},
+		{"missing.png", "hash-missing", false},
+	}
+	for _, tc := range testCases {
+		if actual := resumed.Completed(tc.path, tc.hash); actual != tc.expected {
+			t.Errorf("Completed(%q, %q) = %v, expected %v", tc.path, tc.hash, actual, tc.expected)
+		}
+	}
+}
+
+func TestManifest_FreshRunTruncatesJournal(t *testing.T) {
+	// Arrange
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "run_manifest.jsonl")
+	if err := os.WriteFile(path, []byte(`{"path":"old.png","hash":"h","status":"done"}`+"\n"), 0644); err != nil {
+		t.Fatalf("seeding manifest failed: %v", err)
+	}
+
+	// Act
+	m, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("opening manifest failed: %v", err)
+	}
+	defer m.Close()
+
+	// Assert
+	if m.Completed("old.png", "h") {
+		t.Errorf("expected fresh run to ignore previous entries")
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat manifest failed: %v", err)
+	}
+	if stat.Size() != 0 {
+		t.Errorf("expected empty manifest, got %d bytes", stat.Size())
+	}
+}
+
+func TestManifest_UntrackedPathsAreIgnored(t *testing.T) {
+	// Arrange
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "run_manifest.jsonl")
+	m, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("opening manifest failed: %v", err)
+	}
+
+	// Act
+	recordErr := m.Record("pipeline_error", StatusFailed, errors.New("boom"))
+	m.Close()
+
+	// Assert
+	if recordErr != nil {
+		t.Fatalf("Record failed: %v", recordErr)
+	}
+	if stat, err := os.Stat(path); err != nil || stat.Size() != 0 {
+		t.Errorf("expected untracked path not to be journaled")
+	}
+}
+
+func TestManifest_InterruptedWriteIsInDoubt(t *testing.T) {
+	// Arrange: the run stopped between writing the output and journaling it
+	path := filepath.Join(t.TempDir(), "run_manifest.jsonl")
+	first, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("opening manifest failed: %v", err)
+	}
+	first.Track("torn.png", "hash-torn")
+	first.Track("done.png", "hash-done")
+	first.Record("torn.png", StatusWriting, nil)
+	first.Record("done.png", StatusWriting, nil)
+	first.Record("done.png", StatusDone, nil)
+	first.Close()
+
+	// Act
+	resumed, err := Open(path, true)
+	if err != nil {
+		t.Fatalf("resuming manifest failed: %v", err)
+	}
+	defer resumed.Close()
+	resumed.Track("torn.png", "hash-torn")
+	resumed.Track("done.png", "hash-done")
+
+	// Assert
+	if resumed.Completed("torn.png", "hash-torn") || !resumed.InDoubt("torn.png") {
+		t.Errorf("expected torn.png to be retried and in doubt")
+	}
+	if !resumed.Completed("done.png", "hash-done") || resumed.InDoubt("done.png") {
+		t.Errorf("expected done.png to be completed")
+	}
+	if !resumed.Written("torn.png") || !resumed.Written("done.png") || resumed.Written("other.png") {
+		t.Errorf("expected only torn.png and done.png to be reported written")
+	}
+}
@@ -5,21 +5,43 @@ import (
 	"ocr-tool/internal/ocr/engine"
 )
 
-func NewEngine(engineType string) (OCREngine, error) {
+// EngineOptions holds the settings of every engine, only those of the
+// selected engine are used.
+type EngineOptions struct {
+	Gosseract engine.GosseractOptions `json:"gosseract"`
+	Ollama    engine.OllamaOptions    `json:"ollama"`
+	Retry     RetryPolicy             `json:"-"`
+}
+
+// Validate checks the options of the selected engine, so that mistakes are
+// reported before any image is read rather than as a failed run.
+func (o EngineOptions) Validate(engineType string) error {
+	switch engineType {
+	case "ollama":
+		return o.Ollama.Validate()
+	case "gosseract", "":
+		return o.Gosseract.Validate()
+	default:
+		return fmt.Errorf("unknown engine type: %s", engineType)
+	}
+}
+
+func NewEngine(engineType string, options EngineOptions) (OCREngine, error) {
 	var e OCREngine
 	var err error
-
 	switch engineType {
 	case "ollama":
-		e = engine.NewOllamaEngine("", "")
+		e, err = engine.NewOllamaEngine(options.Ollama)
+		if err != nil {
+			return nil, err
+		}
 	case "gosseract", "":
-		e, err = engine.NewGosseractEngine()
+		e, err = engine.NewGosseractEngine(options.Gosseract)
 		if err != nil {
 			return nil, err
 		}
 	default:
 		return nil, fmt.Errorf("unknown engine type: %s", engineType)
 	}
-
-	return e, nil
+	return WithRetry(e, options.Retry), nil
 }
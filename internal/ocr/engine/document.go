@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"ocr-tool/internal/ocr/layout"
+	"time"
+)
+
+// Document is what an engine recognized in one image. Engines fill what they
+// can: Tesseract transcribes Text with its Layout, Ollama reads the Fields
+// directly.
+type Document struct {
+	Text       string         `json:"text,omitempty"`   // recognized text
+	Fields     *Fields        `json:"fields,omitempty"` // fields read by the engine itself, nil when it only transcribes
+	Layout     *layout.Layout `json:"layout,omitempty"` // word positions and confidences, nil when not reported
+	Confidence float64        `json:"confidence"`       // from 0 to 100, 0 when not reported
+	Engine     string         `json:"engine"`
+	Model      string         `json:"model,omitempty"` // Tesseract languages or Ollama model
+	Timings    Timings        `json:"timings"`
+	Attempts   int            `json:"attempts,omitempty"` // calls made by the retry policy, 0 without one
+}
+
+// Fields are the contact details an engine extracted on its own.
+type Fields struct {
+	Name  string   `json:"name,omitempty"`
+	Email string   `json:"email,omitempty"`
+	Phone string   `json:"phone,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+type Timings struct {
+	Total       time.Duration `json:"total"`                 // wall time of ProcessImage
+	Load        time.Duration `json:"load,omitempty"`        // model loading reported by the engine
+	Recognition time.Duration `json:"recognition,omitempty"` // text recognition alone
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrInvalidResponse marks engine answers that could not be parsed. Models
+// occasionally produce malformed output that a new attempt fixes.
+var ErrInvalidResponse = errors.New("invalid response")
+
+// StatusError is a non-200 answer of an HTTP engine.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // from the Retry-After header, 0 when absent
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed with status: %d", e.StatusCode)
+}
+
+func newStatusError(resp *http.Response) *StatusError {
+	err := &StatusError{StatusCode: resp.StatusCode}
+	if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return err
+}
+
+// IsTransient reports whether a new attempt may succeed: the server was
+// unreachable or dropped the connection, asked to slow down (429), failed on
+// its side (5xx) or answered something unparseable. Cancellation and
+// deadlines are never transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	return errors.Is(err, ErrInvalidResponse) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.As(err, &netErr) && netErr.Timeout()
+}
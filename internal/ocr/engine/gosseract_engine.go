@@ -1,59 +1,348 @@
 package engine
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
+
+	"ocr-tool/internal/ocr/layout"
 
 	"github.com/otiai10/gosseract/v2"
 )
 
+// GosseractOptions configures Tesseract. Zero values keep Tesseract's own
+// defaults.
+type GosseractOptions struct {
+	Languages   []string          `json:"languages"`   // traineddata names such as "eng", "deu", "chi_sim", eng when empty
+	Tessdata    string            `json:"tessdata"`    // directory holding the traineddata files, TESSDATA_PREFIX when empty
+	PageSegMode *int              `json:"psm"`         // page segmentation mode 0-13, 3 (automatic) when nil
+	EngineMode  *int              `json:"oem"`         // OCR engine mode 0-3 (legacy, LSTM, both, default), 3 when nil
+	Whitelist   string            `json:"whitelist"`   // only recognize these characters
+	Blacklist   string            `json:"blacklist"`   // never recognize these characters
+	ConfigFile  string            `json:"config_file"` // Tesseract config file read at initialization
+	Variables   map[string]string `json:"variables"`   // any other Tesseract variable
+
+	Clients int `json:"-"` // most clients, and so recognitions, at once, unlimited when 0; set from the OCR workers
+}
+
+// Validate checks the modes are in range and the files exist.
+func (o GosseractOptions) Validate() error {
+	if o.PageSegMode != nil && (*o.PageSegMode < 0 || *o.PageSegMode > int(gosseract.PSM_RAW_LINE)) {
+		return fmt.Errorf("page segmentation mode %d out of range (0-%d)", *o.PageSegMode, gosseract.PSM_RAW_LINE)
+	}
+	if o.EngineMode != nil && (*o.EngineMode < 0 || *o.EngineMode > 3) {
+		return fmt.Errorf("OCR engine mode %d out of range (0-3)", *o.EngineMode)
+	}
+	if o.Tessdata != "" {
+		if info, err := os.Stat(o.Tessdata); err != nil || !info.IsDir() {
+			return fmt.Errorf("tessdata directory %s not found", o.Tessdata)
+		}
+	}
+	if o.ConfigFile != "" {
+		if _, err := os.Stat(o.ConfigFile); err != nil {
+			return fmt.Errorf("config file: %w", err)
+		}
+	}
+	return nil
+}
+
+// GosseractEngine keeps its Tesseract clients across images: initializing
+// one loads the language data, which costs more than recognizing a small
+// image. A client is created for each concurrent caller, up to
+// Options.Clients, and reused once released. A recognition abandoned by its
+// caller keeps its client until it finishes, so callers then wait for a
+// client rather than running more recognitions than there are OCR workers.
 type GosseractEngine struct {
-	client *gosseract.Client
+	options    GosseractOptions
+	configFile string        // config file passed at initialization, a temporary one when the engine mode is set
+	slots      chan struct{} // one per client in use, nil when unlimited
+
+	mu      sync.Mutex
+	idle    []*gosseract.Client // configured clients waiting for an image
+	clients []*gosseract.Client // every open client, closed by Close
+	busy    sync.WaitGroup      // recognitions running, possibly abandoned by their caller
 }
 
-func NewGosseractEngine() (*GosseractEngine, error) {
-	return &GosseractEngine{}, nil
+func NewGosseractEngine(options GosseractOptions) (*GosseractEngine, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	g := &GosseractEngine{options: options, configFile: options.ConfigFile}
+	if options.Clients > 0 {
+		g.slots = make(chan struct{}, options.Clients)
+	}
+
+	// The engine mode is only read when Tesseract initializes and gosseract
+	// has no setter for it, so it goes through a generated config file
+	if options.EngineMode != nil {
+		configFile, err := writeConfigFile(options.ConfigFile, *options.EngineMode)
+		if err != nil {
+			return nil, err
+		}
+		g.configFile = configFile
+	}
+	return g, nil
 }
 
-func (g *GosseractEngine) ProcessImage(imagePath string) (json.RawMessage, error) {
-	client := gosseract.NewClient()
-	defer client.Close()
-	client.SetConfigFile("digits")
-	client.SetPageSegMode(gosseract.PSM_AUTO)
-	client.SetVariable("tessedit_char_whitelist", "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz@+-.() ")
+// writeConfigFile writes a temporary Tesseract config file holding the
+// content of base, if any, and the engine mode.
+func writeConfigFile(base string, engineMode int) (string, error) {
+	var content []byte
+	if base != "" {
+		var err error
+		if content, err = os.ReadFile(base); err != nil {
+			return "", fmt.Errorf("reading config file: %w", err)
+		}
+		content = append(content, '\n')
+	}
+	content = append(content, fmt.Sprintf("tessedit_ocr_engine_mode %d\n", engineMode)...)
 
-	client.SetImage(imagePath)
-	text, err := client.Text()
+	file, err := os.CreateTemp("", "ocr-tool-tesseract-*.cfg")
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract text from image %s: %w", imagePath, err)
+		return "", fmt.Errorf("creating config file: %w", err)
 	}
-	jsonBytes, err := textToJSON(text)
+	defer file.Close()
+	if _, err := file.Write(content); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("writing config file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// acquire hands out an idle client or creates one, waiting until ctx ends
+// while every client allowed is in use.
+func (g *GosseractEngine) acquire(ctx context.Context) (*gosseract.Client, error) {
+	if g.slots != nil {
+		select {
+		case g.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	client, err := g.take()
 	if err != nil {
-		log.Printf("Failed to convert text to JSON: %v\n", err)
-		return json.RawMessage{}, nil
+		g.freeSlot()
 	}
-	return jsonBytes, nil
+	return client, err
 }
 
-func (g *GosseractEngine) Close() error {
-	if g.client != nil {
-		return g.client.Close()
+func (g *GosseractEngine) take() (*gosseract.Client, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if n := len(g.idle); n > 0 {
+		client := g.idle[n-1]
+		g.idle = g.idle[:n-1]
+		return client, nil
+	}
+
+	client := gosseract.NewClient()
+	if err := g.configure(client); err != nil {
+		client.Close()
+		return nil, err
+	}
+	g.clients = append(g.clients, client)
+	return client, nil
+}
+
+// release returns the client to the pool, or closes it when it failed and
+// may be left in a bad state. A failure to load the image does not affect
+// the client but is rare enough not to tell apart.
+func (g *GosseractEngine) release(client *gosseract.Client, failed bool) {
+	defer g.freeSlot()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !failed {
+		g.idle = append(g.idle, client)
+		return
+	}
+	g.clients = slices.DeleteFunc(g.clients, func(c *gosseract.Client) bool { return c == client })
+	client.Close()
+}
+
+func (g *GosseractEngine) freeSlot() {
+	if g.slots != nil {
+		<-g.slots
+	}
+}
+
+// configure applies the options to a fresh client.
+func (g *GosseractEngine) configure(client *gosseract.Client) error {
+	o := g.options
+	if len(o.Languages) > 0 {
+		if err := client.SetLanguage(o.Languages...); err != nil {
+			return fmt.Errorf("setting languages: %w", err)
+		}
+	}
+	if o.Tessdata != "" {
+		if err := client.SetTessdataPrefix(o.Tessdata); err != nil {
+			return fmt.Errorf("setting tessdata: %w", err)
+		}
+	}
+	if g.configFile != "" {
+		if err := client.SetConfigFile(g.configFile); err != nil {
+			return fmt.Errorf("setting config file: %w", err)
+		}
+	}
+
+	mode := gosseract.PSM_AUTO
+	if o.PageSegMode != nil {
+		mode = gosseract.PageSegMode(*o.PageSegMode)
+	}
+	if err := client.SetPageSegMode(mode); err != nil {
+		return fmt.Errorf("setting page segmentation mode: %w", err)
+	}
+
+	if o.Whitelist != "" {
+		if err := client.SetWhitelist(o.Whitelist); err != nil {
+			return fmt.Errorf("setting whitelist: %w", err)
+		}
+	}
+	if o.Blacklist != "" {
+		if err := client.SetBlacklist(o.Blacklist); err != nil {
+			return fmt.Errorf("setting blacklist: %w", err)
+		}
+	}
+	for key, value := range o.Variables {
+		if err := client.SetVariable(gosseract.SettableVariable(key), value); err != nil {
+			return fmt.Errorf("setting variable %s: %w", key, err)
+		}
 	}
 	return nil
 }
 
-func textToJSON(text string) (json.RawMessage, error) {
-	cleanText := strings.TrimSpace(text)
-	cleanText = strings.ReplaceAll(cleanText, "\n", " ")
-	cleanText = strings.ReplaceAll(cleanText, "\r", " ")
-	cleanText = strings.ReplaceAll(cleanText, "\t", " ")
-	cleanText = strings.ReplaceAll(cleanText, "  ", " ")
-	data := map[string]string{"text": cleanText}
-	jsonBytes, err := json.Marshal(data)
+// ProcessImage recognizes the image on a pooled client. Tesseract cannot be
+// interrupted, so when ctx ends first the call returns ctx.Err() at once and
+// the recognition finishes in the background before its client is released.
+// ctx also bounds the wait for a client.
+func (g *GosseractEngine) ProcessImage(ctx context.Context, image io.Reader) (Document, error) {
+	start := time.Now()
+	imageData, err := io.ReadAll(image)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return Document{}, err
+	}
+
+	client, err := g.acquire(ctx)
+	if err != nil {
+		return Document{}, err
+	}
+
+	type recognized struct {
+		doc Document
+		err error
+	}
+	done := make(chan recognized, 1)
+	g.busy.Add(1)
+	go func() {
+		defer g.busy.Done()
+		doc, err := g.recognize(client, imageData)
+		g.release(client, err != nil)
+		done <- recognized{doc, err}
+	}()
+
+	select {
+	case r := <-done:
+		r.doc.Timings.Total = time.Since(start)
+		return r.doc, r.err
+	case <-ctx.Done():
+		return Document{}, ctx.Err()
+	}
+}
+
+func (g *GosseractEngine) recognize(client *gosseract.Client, imageData []byte) (Document, error) {
+	if err := client.SetImageFromBytes(imageData); err != nil {
+		return Document{}, fmt.Errorf("failed to load image: %w", err)
+	}
+	recognition := time.Now()
+	// gosseract sets the image again before every request, which discards
+	// the previous recognition, so the text is built from the word boxes
+	// rather than recognizing the image a second time for it
+	page, err := pageLayout(client)
+	var text string
+	if err != nil {
+		log.Printf("Failed to get word boxes: %v\n", err)
+		if text, err = client.Text(); err != nil {
+			return Document{}, fmt.Errorf("failed to extract text from image: %w", err)
+		}
+	} else {
+		text = page.Text()
+	}
+	doc := Document{
+		Text:    cleanText(text),
+		Engine:  "gosseract",
+		Model:   g.model(),
+		Timings: Timings{Recognition: time.Since(recognition)},
+	}
+	if len(page.Pages) > 0 {
+		doc.Layout = &page
+		doc.Confidence = page.Confidence()
+	}
+	return doc, nil
+}
+
+// model names the traineddata used, as Tesseract does on its command line.
+func (g *GosseractEngine) model() string {
+	if len(g.options.Languages) == 0 {
+		return "eng"
+	}
+	return strings.Join(g.options.Languages, "+")
+}
+
+// pageLayout collects the words Tesseract recognized with their position in
+// the reading order. The layout is empty when Tesseract reports none.
+func pageLayout(client *gosseract.Client) (layout.Layout, error) {
+	boxes, err := client.GetBoundingBoxesVerbose()
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal text to JSON: %w", err)
+		return layout.Layout{}, err
+	}
+
+	words := make([]layout.Word, len(boxes))
+	positions := make([]layout.Position, len(boxes))
+	for i, box := range boxes {
+		words[i] = layout.Word{
+			Text:       box.Word,
+			BBox:       layout.BBox{box.Box.Min.X, box.Box.Min.Y, box.Box.Max.X, box.Box.Max.Y},
+			Confidence: box.Confidence,
+		}
+		positions[i] = layout.Position{Block: box.BlockNum, Paragraph: box.ParNum, Line: box.LineNum}
 	}
-	return json.RawMessage(jsonBytes), nil
+	return layout.Build(words, positions), nil
+}
+
+// Close waits for the running recognitions, including those whose caller
+// gave up, and closes every client.
+func (g *GosseractEngine) Close() error {
+	g.busy.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var errs []error
+	for _, client := range g.clients {
+		errs = append(errs, client.Close())
+	}
+	g.clients, g.idle = nil, nil
+
+	if g.configFile != "" && g.configFile != g.options.ConfigFile {
+		os.Remove(g.configFile)
+	}
+	return errors.Join(errs...)
+}
+
+// cleanText puts the text on a single line.
+func cleanText(text string) string {
+	clean := strings.TrimSpace(text)
+	clean = strings.ReplaceAll(clean, "\n", " ")
+	clean = strings.ReplaceAll(clean, "\r", " ")
+	clean = strings.ReplaceAll(clean, "\t", " ")
+	clean = strings.ReplaceAll(clean, "  ", " ")
+	return clean
 }
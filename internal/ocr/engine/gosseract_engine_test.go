@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGosseractOptions_Validate(t *testing.T) {
+	mode := func(v int) *int { return &v }
+
+	testCases := []struct {
+		name    string
+		options GosseractOptions
+		valid   bool
+	}{
+		{name: "defaults", options: GosseractOptions{}, valid: true},
+		{name: "modes in range", options: GosseractOptions{PageSegMode: mode(6), EngineMode: mode(1)}, valid: true},
+		{name: "page segmentation mode out of range", options: GosseractOptions{PageSegMode: mode(14)}},
+		{name: "engine mode out of range", options: GosseractOptions{EngineMode: mode(4)}},
+		{name: "missing tessdata", options: GosseractOptions{Tessdata: filepath.Join(t.TempDir(), "missing")}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			err := tc.options.Validate()
+
+			// Assert
+			if tc.valid && err != nil {
+				t.Errorf("expected valid options, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestNewGosseractEngine_EngineModeConfigFile(t *testing.T) {
+	// Arrange
+	base := filepath.Join(t.TempDir(), "base.cfg")
+	if err := os.WriteFile(base, []byte("preserve_interword_spaces 1"), 0644); err != nil {
+		t.Fatalf("writing config file failed: %v", err)
+	}
+	oem := 1
+
+	// Act
+	g, err := NewGosseractEngine(GosseractOptions{ConfigFile: base, EngineMode: &oem})
+	if err != nil {
+		t.Fatalf("creating engine failed: %v", err)
+	}
+	content, readErr := os.ReadFile(g.configFile)
+	closeErr := g.Close()
+
+	// Assert
+	if readErr != nil || closeErr != nil {
+		t.Fatalf("unexpected errors: read=%v close=%v", readErr, closeErr)
+	}
+	for _, line := range []string{"preserve_interword_spaces 1", "tessedit_ocr_engine_mode 1"} {
+		if !strings.Contains(string(content), line) {
+			t.Errorf("expected config file to contain %q, got %q", line, content)
+		}
+	}
+	if _, err := os.Stat(g.configFile); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary config file to be removed, got %v", err)
+	}
+	if _, err := os.Stat(base); err != nil {
+		t.Errorf("expected the base config file to be kept, got %v", err)
+	}
+}
+
+func TestGosseractEngine_AcquireWaitsForClient(t *testing.T) {
+	// Arrange: the only client is held, as by an abandoned recognition
+	g, err := NewGosseractEngine(GosseractOptions{Clients: 1})
+	if err != nil {
+		t.Fatalf("creating engine failed: %v", err)
+	}
+	defer g.Close()
+	client, err := g.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquiring client failed: %v", err)
+	}
+
+	// Act
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, waitErr := g.acquire(ctx)
+	g.release(client, false)
+	reused, reuseErr := g.acquire(context.Background())
+
+	// Assert
+	if !errors.Is(waitErr, context.DeadlineExceeded) {
+		t.Errorf("expected to wait until the deadline, got %v", waitErr)
+	}
+	if reuseErr != nil || reused != client {
+		t.Errorf("expected the released client to be reused, got %p, %v", reused, reuseErr)
+	}
+	if len(g.clients) != 1 {
+		t.Errorf("expected 1 client, got %d", len(g.clients))
+	}
+	g.release(reused, false)
+}
+
+// benchmarkImage returns a small PNG with a few dark bars, cheap to
+// recognize so that client initialization dominates.
+func benchmarkImage(b *testing.B) []byte {
+	img := image.NewGray(image.Rect(0, 0, 200, 60))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for x := 20; x < 180; x += 20 {
+		for y := 20; y < 40; y++ {
+			for dx := 0; dx < 8; dx++ {
+				img.Pix[y*img.Stride+x+dx] = 0
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		b.Fatalf("encoding PNG failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func requireTesseract(b *testing.B, data []byte) {
+	g, err := NewGosseractEngine(GosseractOptions{})
+	if err != nil {
+		b.Fatalf("creating engine failed: %v", err)
+	}
+	defer g.Close()
+	if _, err := g.ProcessImage(context.Background(), bytes.NewReader(data)); err != nil {
+		b.Skipf("tesseract unavailable: %v", err)
+	}
+}
+
+// BenchmarkGosseractEngine_PerImage initializes Tesseract for every image,
+// as the engine did before keeping its clients.
+func BenchmarkGosseractEngine_PerImage(b *testing.B) {
+	data := benchmarkImage(b)
+	requireTesseract(b, data)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g, _ := NewGosseractEngine(GosseractOptions{})
+			if _, err := g.ProcessImage(context.Background(), bytes.NewReader(data)); err != nil {
+				b.Error(err)
+			}
+			g.Close()
+		}
+	})
+}
+
+func BenchmarkGosseractEngine_Pooled(b *testing.B) {
+	data := benchmarkImage(b)
+	requireTesseract(b, data)
+	g, err := NewGosseractEngine(GosseractOptions{})
+	if err != nil {
+		b.Fatalf("creating engine failed: %v", err)
+	}
+	defer g.Close()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := g.ProcessImage(context.Background(), bytes.NewReader(data)); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
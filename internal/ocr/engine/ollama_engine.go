@@ -2,31 +2,84 @@ package engine
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// OllamaOptions configures the Ollama server and model. Empty values keep
+// the defaults of the engine, or of the model for the generation options.
+type OllamaOptions struct {
+	BaseURL   string                  `json:"base_url"`   // http://localhost:11434 when empty
+	Model     string                  `json:"model"`      // llama3.2-vision when empty
+	KeepAlive string                  `json:"keep_alive"` // how long the model stays loaded after a request: a duration such as "10m", or seconds, negative to keep it loaded
+	Options   OllamaGenerationOptions `json:"options"`
+}
+
+// OllamaGenerationOptions are sent as the "options" of every request. A
+// pinned Seed with a zero Temperature makes the answers reproducible.
+type OllamaGenerationOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	NumCtx      *int     `json:"num_ctx,omitempty"`     // context window in tokens
+	NumPredict  *int     `json:"num_predict,omitempty"` // most tokens generated, -1 for no limit
+}
+
+// Validate checks the base URL and the ranges of the generation options.
+func (o OllamaOptions) Validate() error {
+	if o.BaseURL != "" {
+		u, err := url.Parse(o.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid Ollama base URL %q", o.BaseURL)
+		}
+	}
+	if o.KeepAlive != "" {
+		if _, err := keepAliveJSON(o.KeepAlive); err != nil {
+			return err
+		}
+	}
+	if t := o.Options.Temperature; t != nil && *t < 0 {
+		return fmt.Errorf("temperature must not be negative")
+	}
+	if n := o.Options.NumCtx; n != nil && *n <= 0 {
+		return fmt.Errorf("num_ctx must be positive")
+	}
+	if n := o.Options.NumPredict; n != nil && *n < -2 {
+		return fmt.Errorf("num_predict must be -1, -2 or positive")
+	}
+	return nil
+}
+
 type OllamaEngine struct {
-	baseURL string
-	model   string
-	client  *http.Client
+	baseURL   string
+	model     string
+	keepAlive json.RawMessage
+	options   OllamaGenerationOptions
+	client    *http.Client
 }
 
 type OllamaRequest struct {
-	Model  string   `json:"model"`
-	Prompt string   `json:"prompt"`
-	Images []string `json:"images"`
-	Stream bool     `json:"stream"`
+	Model     string                   `json:"model"`
+	Prompt    string                   `json:"prompt"`
+	Images    []string                 `json:"images"`
+	Stream    bool                     `json:"stream"`
+	KeepAlive json.RawMessage          `json:"keep_alive,omitempty"`
+	Options   *OllamaGenerationOptions `json:"options,omitempty"`
 }
 
 type OllamaResponse struct {
-	Response json.RawMessage `json:"response"`
-	Done     bool            `json:"done"`
+	Response      json.RawMessage `json:"response"`
+	Done          bool            `json:"done"`
+	TotalDuration int64           `json:"total_duration"` // nanoseconds
+	LoadDuration  int64           `json:"load_duration"`  // nanoseconds
 }
 
 const (
@@ -34,25 +87,48 @@ const (
 	defaultModel   = "llama3.2-vision"
 )
 
-func NewOllamaEngine(baseURL, model string) *OllamaEngine {
-	if baseURL == "" {
-		baseURL = defaultBaseURL
+func NewOllamaEngine(options OllamaOptions) (*OllamaEngine, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	if options.BaseURL == "" {
+		options.BaseURL = defaultBaseURL
 	}
-	if model == "" {
-		model = defaultModel
+	if options.Model == "" {
+		options.Model = defaultModel
 	}
 
-	return &OllamaEngine{
-		baseURL: baseURL,
-		model:   model,
+	o := &OllamaEngine{
+		baseURL: strings.TrimSuffix(options.BaseURL, "/"),
+		model:   options.Model,
+		options: options.Options,
 		client:  &http.Client{},
 	}
+	if options.KeepAlive != "" {
+		o.keepAlive, _ = keepAliveJSON(options.KeepAlive)
+	}
+	return o, nil
+}
+
+// keepAliveJSON encodes keep_alive as Ollama reads it: a number of seconds
+// or a duration string.
+func keepAliveJSON(value string) (json.RawMessage, error) {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return json.RawMessage(value), nil
+	}
+	if _, err := time.ParseDuration(value); err != nil {
+		return nil, fmt.Errorf("invalid keep_alive %q: %w", value, err)
+	}
+	return json.Marshal(value)
 }
 
-func (o *OllamaEngine) ProcessImage(imagePath string) (json.RawMessage, error) {
-	imageData, err := os.ReadFile(imagePath)
+// ProcessImage asks the model for the fields of the image. The request is
+// abandoned when ctx ends.
+func (o *OllamaEngine) ProcessImage(ctx context.Context, image io.Reader) (Document, error) {
+	start := time.Now()
+	imageData, err := io.ReadAll(image)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read image: %w", err)
+		return Document{}, fmt.Errorf("failed to read image: %w", err)
 	}
 
 	encodedImage := base64.StdEncoding.EncodeToString(imageData)
@@ -86,42 +162,93 @@ Your job:
 * If a field is missing or unreadable, use an empty string (or default array for Tags).  
 * Make sure the JSON is syntactically correct – double quotes, no trailing commas, no comments.
 				`,
-		Images: []string{encodedImage},
-		Stream: false,
+		Images:    []string{encodedImage},
+		Stream:    false,
+		KeepAlive: o.keepAlive,
+	}
+	if o.options != (OllamaGenerationOptions{}) {
+		request.Options = &o.options
 	}
 
 	jsonData, err := json.Marshal(request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return Document{}, fmt.Errorf("failed to marshal request: %w", err)
 	}
 	// fmt.Printf("Sending request to Ollama: %s\n", string(jsonData))
 
-	resp, err := o.client.Post(o.baseURL+"/api/generate", "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/api/generate", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return Document{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ollama request failed with status: %d", resp.StatusCode)
+		return Document{}, fmt.Errorf("ollama %w", newStatusError(resp))
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return Document{}, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	var ollamaResp OllamaResponse
 	if err := json.Unmarshal(body, &ollamaResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		return Document{}, fmt.Errorf("failed to unmarshal response: %w: %w", ErrInvalidResponse, err)
 	}
 
 	jsonObj, err := extractJSON(string(ollamaResp.Response))
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract JSON from response: %w", err)
+		return Document{}, fmt.Errorf("failed to extract JSON from response: %w: %w", ErrInvalidResponse, err)
 	}
 
-	return jsonObj, nil
+	// The model reports no confidence
+	doc := Document{
+		Engine: "ollama",
+		Model:  o.model,
+		Timings: Timings{
+			Total:       time.Since(start),
+			Load:        time.Duration(ollamaResp.LoadDuration),
+			Recognition: time.Duration(ollamaResp.TotalDuration - ollamaResp.LoadDuration),
+		},
+	}
+	if fields, ok := parseFields(jsonObj); ok {
+		doc.Fields = &fields
+	} else {
+		doc.Text = string(jsonObj)
+	}
+	return doc, nil
+}
+
+// parseFields reads the fields the prompt asks for. Models sometimes answer
+// Tags as a single string, which becomes one tag.
+func parseFields(data json.RawMessage) (Fields, bool) {
+	var answer struct {
+		Name  string          `json:"Name"`
+		Email string          `json:"Email"`
+		Phone string          `json:"Phone"`
+		Tags  json.RawMessage `json:"Tags"`
+	}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return Fields{}, false
+	}
+
+	fields := Fields{Name: answer.Name, Email: answer.Email, Phone: answer.Phone}
+	if len(answer.Tags) > 0 && json.Unmarshal(answer.Tags, &fields.Tags) != nil {
+		var tag string
+		if err := json.Unmarshal(answer.Tags, &tag); err != nil {
+			return Fields{}, false
+		}
+		if tag != "" {
+			fields.Tags = []string{tag}
+		}
+	}
+	return fields, true
 }
 
 func (o *OllamaEngine) Close() error {
@@ -2,9 +2,13 @@ package engine
 
 // test extractJSON function
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -67,3 +71,104 @@ func TestExtractJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFields(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected Fields
+		ok       bool
+	}{
+		{
+			name:     "tags as array",
+			input:    `{"Name": "Sandra", "Email": "de@gmail.com", "Phone": "+41799123123", "Tags": ["a", "b"]}`,
+			expected: Fields{Name: "Sandra", Email: "de@gmail.com", Phone: "+41799123123", Tags: []string{"a", "b"}},
+			ok:       true,
+		},
+		{
+			name:     "tags as string",
+			input:    `{"Name": "Sandra", "Tags": "Age: 54, Birthday: May 14th, 1971"}`,
+			expected: Fields{Name: "Sandra", Tags: []string{"Age: 54, Birthday: May 14th, 1971"}},
+			ok:       true,
+		},
+		{
+			name:  "tags of another type",
+			input: `{"Name": "Sandra", "Tags": 3}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// act
+			actual, ok := parseFields(json.RawMessage(tc.input))
+
+			// assert
+			if ok != tc.ok || !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected (%+v, %v), got (%+v, %v)", tc.expected, tc.ok, actual, ok)
+			}
+		})
+	}
+}
+
+func TestOllamaEngine_SendsOptions(t *testing.T) {
+	// arrange
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{"response": "{\"Name\": \"Sandra\"}", "done": true}`))
+	}))
+	defer server.Close()
+	temperature, seed := 0.0, 42
+	e, err := NewOllamaEngine(OllamaOptions{
+		BaseURL:   server.URL + "/",
+		Model:     "llava:13b",
+		KeepAlive: "10m",
+		Options:   OllamaGenerationOptions{Temperature: &temperature, Seed: &seed},
+	})
+	if err != nil {
+		t.Fatalf("creating engine failed: %v", err)
+	}
+
+	// act
+	doc, err := e.ProcessImage(context.Background(), strings.NewReader("image"))
+
+	// assert
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]any{"temperature": 0.0, "seed": 42.0}
+	if received["model"] != "llava:13b" || received["keep_alive"] != "10m" || !reflect.DeepEqual(received["options"], expected) {
+		t.Errorf("unexpected request: model=%v keep_alive=%v options=%v", received["model"], received["keep_alive"], received["options"])
+	}
+	if doc.Model != "llava:13b" {
+		t.Errorf("expected the document to name the model, got %q", doc.Model)
+	}
+}
+
+func TestOllamaOptions_Validate(t *testing.T) {
+	negative := -0.5
+
+	testCases := []struct {
+		name    string
+		options OllamaOptions
+		valid   bool
+	}{
+		{name: "defaults", options: OllamaOptions{}, valid: true},
+		{name: "keep alive in seconds", options: OllamaOptions{KeepAlive: "-1"}, valid: true},
+		{name: "invalid keep alive", options: OllamaOptions{KeepAlive: "forever"}},
+		{name: "missing scheme", options: OllamaOptions{BaseURL: "localhost:11434"}},
+		{name: "negative temperature", options: OllamaOptions{Options: OllamaGenerationOptions{Temperature: &negative}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// act
+			err := tc.options.Validate()
+
+			// assert
+			if tc.valid != (err == nil) {
+				t.Errorf("expected valid=%v, got %v", tc.valid, err)
+			}
+		})
+	}
+}
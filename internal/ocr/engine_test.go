@@ -0,0 +1,44 @@
+package ocr
+
+import (
+	"ocr-tool/internal/ocr/engine"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngineOptions_Validate(t *testing.T) {
+	mode := func(v int) *int { return &v }
+
+	testCases := []struct {
+		name       string
+		engineType string
+		options    EngineOptions
+		valid      bool
+	}{
+		{name: "defaults", engineType: "gosseract", valid: true},
+		{name: "page segmentation mode out of range", engineType: "gosseract", options: EngineOptions{Gosseract: engine.GosseractOptions{PageSegMode: mode(99)}}},
+		{name: "engine mode out of range", engineType: "gosseract", options: EngineOptions{Gosseract: engine.GosseractOptions{EngineMode: mode(7)}}},
+		{name: "missing tessdata", engineType: "", options: EngineOptions{Gosseract: engine.GosseractOptions{Tessdata: filepath.Join(t.TempDir(), "missing")}}},
+		{name: "ollama defaults", engineType: "ollama", valid: true},
+		{name: "invalid ollama url", engineType: "ollama", options: EngineOptions{Ollama: engine.OllamaOptions{BaseURL: "localhost:11434"}}},
+		{name: "invalid keep alive", engineType: "ollama", options: EngineOptions{Ollama: engine.OllamaOptions{KeepAlive: "forever"}}},
+		{name: "empty context window", engineType: "ollama", options: EngineOptions{Ollama: engine.OllamaOptions{Options: engine.OllamaGenerationOptions{NumCtx: mode(0)}}}},
+		{name: "options of another engine", engineType: "ollama", options: EngineOptions{Gosseract: engine.GosseractOptions{PageSegMode: mode(99)}}, valid: true},
+		{name: "unknown engine", engineType: "cuneiform"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			err := tc.options.Validate(tc.engineType)
+
+			// Assert
+			if tc.valid && err != nil {
+				t.Errorf("expected valid options, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
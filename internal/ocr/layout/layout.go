@@ -0,0 +1,197 @@
+// Package layout describes where an OCR engine found the text of an image:
+// pages made of blocks, lines and words, each with a bounding box and a
+// confidence.
+package layout
+
+import (
+	"strings"
+	"unicode"
+)
+
+// BBox is a bounding box in pixels of the OCRed image, [left, top, right,
+// bottom] as in hOCR.
+type BBox [4]int
+
+// Union returns the smallest box holding both boxes. The zero box is empty.
+func (b BBox) Union(o BBox) BBox {
+	if b == (BBox{}) {
+		return o
+	}
+	if o == (BBox{}) {
+		return b
+	}
+	return BBox{min(b[0], o[0]), min(b[1], o[1]), max(b[2], o[2]), max(b[3], o[3])}
+}
+
+// Confidences are from 0 to 100. Those of pages, blocks and lines are the
+// mean of their words.
+type (
+	Layout struct {
+		Pages []Page `json:"pages"`
+	}
+
+	Page struct {
+		BBox       BBox    `json:"bbox"`
+		Confidence float64 `json:"confidence"`
+		Blocks     []Block `json:"blocks"`
+	}
+
+	Block struct {
+		BBox       BBox    `json:"bbox"`
+		Confidence float64 `json:"confidence"`
+		Lines      []Line  `json:"lines"`
+	}
+
+	Line struct {
+		BBox       BBox    `json:"bbox"`
+		Confidence float64 `json:"confidence"`
+		Words      []Word  `json:"words"`
+	}
+
+	Word struct {
+		Text       string  `json:"text"`
+		BBox       BBox    `json:"bbox"`
+		Confidence float64 `json:"confidence"`
+	}
+)
+
+// Position places a word in the reading order reported by the engine.
+// Paragraphs only separate lines, they are not kept as a level.
+type Position struct {
+	Block, Paragraph, Line int
+}
+
+// Build groups the words of a single page, given in reading order, into
+// blocks and lines. Words without text are dropped.
+func Build(words []Word, positions []Position) Layout {
+	var page Page
+	var last Position
+	for i, word := range words {
+		if strings.TrimSpace(word.Text) == "" {
+			continue
+		}
+		pos := positions[i]
+		if len(page.Blocks) == 0 || pos.Block != last.Block {
+			page.Blocks = append(page.Blocks, Block{})
+		}
+		block := &page.Blocks[len(page.Blocks)-1]
+		if len(block.Lines) == 0 || pos != last {
+			block.Lines = append(block.Lines, Line{})
+		}
+		line := &block.Lines[len(block.Lines)-1]
+		line.Words = append(line.Words, word)
+		last = pos
+	}
+	if len(page.Blocks) == 0 {
+		return Layout{}
+	}
+
+	var pageSum float64
+	var pageWords int
+	for b := range page.Blocks {
+		block := &page.Blocks[b]
+		var blockSum float64
+		var blockWords int
+		for l := range block.Lines {
+			line := &block.Lines[l]
+			var lineSum float64
+			for _, word := range line.Words {
+				line.BBox = line.BBox.Union(word.BBox)
+				lineSum += word.Confidence
+			}
+			line.Confidence = lineSum / float64(len(line.Words))
+			block.BBox = block.BBox.Union(line.BBox)
+			blockSum += lineSum
+			blockWords += len(line.Words)
+		}
+		block.Confidence = blockSum / float64(blockWords)
+		page.BBox = page.BBox.Union(block.BBox)
+		pageSum += blockSum
+		pageWords += blockWords
+	}
+	page.Confidence = pageSum / float64(pageWords)
+	return Layout{Pages: []Page{page}}
+}
+
+// Words returns every word of the layout in reading order.
+func (l Layout) Words() []Word {
+	var words []Word
+	for _, page := range l.Pages {
+		for _, block := range page.Blocks {
+			for _, line := range block.Lines {
+				words = append(words, line.Words...)
+			}
+		}
+	}
+	return words
+}
+
+// Text returns the words of the layout in reading order, separated by
+// spaces within a line and by newlines between lines.
+func (l Layout) Text() string {
+	var lines []string
+	for _, page := range l.Pages {
+		for _, block := range page.Blocks {
+			for _, line := range block.Lines {
+				words := make([]string, len(line.Words))
+				for i, word := range line.Words {
+					words[i] = strings.TrimSpace(word.Text)
+				}
+				lines = append(lines, strings.Join(words, " "))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Confidence is the mean confidence of all words, 0 without words.
+func (l Layout) Confidence() float64 {
+	words := l.Words()
+	if len(words) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, word := range words {
+		sum += word.Confidence
+	}
+	return sum / float64(len(words))
+}
+
+// ConfidenceOf returns the lowest confidence of the words a value was read
+// from, such as an email address or phone number found in the text. Values
+// joined with "; " are matched separately. ok is false when no word matches.
+func (l Layout) ConfidenceOf(value string) (confidence float64, ok bool) {
+	words := l.Words()
+	for _, token := range strings.Split(value, "; ") {
+		token = normalize(token)
+		if token == "" {
+			continue
+		}
+		for _, word := range words {
+			text := normalize(word.Text)
+			if text == "" || !(strings.Contains(text, token) || len(text) >= 3 && strings.Contains(token, text)) {
+				continue
+			}
+			if !ok || word.Confidence < confidence {
+				confidence = word.Confidence
+			}
+			ok = true
+		}
+	}
+	return confidence, ok
+}
+
+// normalize drops the punctuation OCR attaches to words, keeping what makes
+// up emails and phone numbers.
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return unicode.ToLower(r)
+		case r == '@' || r == '.' || r == '_' || r == '-':
+			return r
+		default:
+			return -1
+		}
+	}, s)
+}
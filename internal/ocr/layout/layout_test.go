@@ -0,0 +1,65 @@
+package layout
+
+import (
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	// Arrange
+	words := []Word{
+		{Text: "Jane", BBox: BBox{10, 10, 50, 20}, Confidence: 90},
+		{Text: "Doe", BBox: BBox{60, 10, 90, 20}, Confidence: 80},
+		{Text: "jane@example.com,", BBox: BBox{10, 30, 120, 40}, Confidence: 40},
+		{Text: " ", BBox: BBox{0, 0, 200, 200}, Confidence: 0},
+		{Text: "+41799123123", BBox: BBox{10, 100, 110, 110}, Confidence: 70},
+	}
+	positions := []Position{
+		{Block: 1, Paragraph: 1, Line: 1},
+		{Block: 1, Paragraph: 1, Line: 1},
+		{Block: 1, Paragraph: 1, Line: 2},
+		{Block: 1, Paragraph: 1, Line: 2},
+		{Block: 2, Paragraph: 1, Line: 1},
+	}
+
+	// Act
+	layout := Build(words, positions)
+
+	// Assert
+	if len(layout.Pages) != 1 || len(layout.Pages[0].Blocks) != 2 {
+		t.Fatalf("expected 1 page with 2 blocks, got %+v", layout)
+	}
+	first := layout.Pages[0].Blocks[0]
+	if len(first.Lines) != 2 || len(first.Lines[0].Words) != 2 || len(first.Lines[1].Words) != 1 {
+		t.Fatalf("expected lines of 2 and 1 words, got %+v", first.Lines)
+	}
+	if first.Lines[0].BBox != (BBox{10, 10, 90, 20}) || first.BBox != (BBox{10, 10, 120, 40}) {
+		t.Errorf("unexpected boxes: line %v, block %v", first.Lines[0].BBox, first.BBox)
+	}
+	if text := layout.Text(); text != "Jane Doe\njane@example.com,\n+41799123123" {
+		t.Errorf("unexpected text %q", text)
+	}
+	if first.Lines[0].Confidence != 85 || layout.Pages[0].Confidence != 70 || layout.Confidence() != 70 {
+		t.Errorf("unexpected confidences: line %v, page %v, layout %v", first.Lines[0].Confidence, layout.Pages[0].Confidence, layout.Confidence())
+	}
+
+	testCases := []struct {
+		value      string
+		confidence float64
+		ok         bool
+	}{
+		{value: "jane@example.com", confidence: 40, ok: true},
+		{value: "+41799123123; Jane Doe", confidence: 70, ok: true},
+		{value: "nobody@example.org"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			// Act
+			confidence, ok := layout.ConfidenceOf(tc.value)
+
+			// Assert
+			if ok != tc.ok || confidence != tc.confidence {
+				t.Errorf("expected (%v, %v), got (%v, %v)", tc.confidence, tc.ok, confidence, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"ocr-tool/internal/ocr/engine"
+	"time"
+)
+
+// RetryPolicy retries the engine calls failing with transient errors, see
+// engine.IsTransient. Zero values are replaced by DefaultRetryPolicy.
+type RetryPolicy struct {
+	MaxAttempts int           // calls per image including the first, 1 disables retries
+	BaseDelay   time.Duration // wait before the second attempt, doubled for each next one
+	MaxDelay    time.Duration // cap of the wait between attempts
+}
+
+// DefaultRetryPolicy covers an Ollama server loading its model, which takes
+// a few seconds and answers 500 meanwhile.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	defaults := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaults.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = max(defaults.MaxDelay, p.BaseDelay)
+	}
+	return p
+}
+
+// delay is the wait before the given attempt, 2 or more: the base delay
+// doubled for every attempt after the second, capped, then reduced by up to
+// half at random so workers failing together do not retry together. A
+// Retry-After requested by the server is honoured up to the cap.
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	d := p.BaseDelay
+	for i := 2; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	d -= time.Duration(rand.Int64N(int64(d)/2 + 1))
+
+	var statusErr *engine.StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > d {
+		d = min(statusErr.RetryAfter, p.MaxDelay)
+	}
+	return d
+}
+
+type retryEngine struct {
+	engine OCREngine
+	policy RetryPolicy
+}
+
+// WithRetry wraps e so that transient failures are retried according to the
+// policy. The Attempts of the returned documents count the calls made.
+func WithRetry(e OCREngine, policy RetryPolicy) OCREngine {
+	return &retryEngine{engine: e, policy: policy.withDefaults()}
+}
+
+func (r *retryEngine) ProcessImage(ctx context.Context, image io.Reader) (Document, error) {
+	// Every attempt needs the image from the start
+	data, err := io.ReadAll(image)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		doc, err := r.engine.ProcessImage(ctx, bytes.NewReader(data))
+		if err == nil {
+			doc.Attempts = attempt
+			return doc, nil
+		}
+		if attempt == r.policy.MaxAttempts || !engine.IsTransient(err) {
+			if attempt > 1 {
+				return Document{}, fmt.Errorf("after %d attempts: %w", attempt, err)
+			}
+			return Document{}, err
+		}
+
+		wait := r.policy.delay(attempt+1, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return Document{}, fmt.Errorf("after %d attempts: %w", attempt, ctx.Err())
+		}
+	}
+}
+
+func (r *retryEngine) Close() error {
+	return r.engine.Close()
+}
@@ -0,0 +1,125 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"ocr-tool/internal/ocr/engine"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const validAnswer = `{"response": "{\"Name\": \"Sandra\", \"Email\": \"de@gmail.com\", \"Phone\": \"\", \"Tags\": []}", "done": true}`
+
+// ollamaStandIn answers the requests with the given statuses in turn, then
+// with a valid answer.
+func ollamaStandIn(t *testing.T, answers ...func(http.ResponseWriter)) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		if call <= len(answers) {
+			answers[call-1](w)
+			return
+		}
+		w.Write([]byte(validAnswer))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newOllama(t *testing.T, baseURL string) *engine.OllamaEngine {
+	e, err := engine.NewOllamaEngine(engine.OllamaOptions{BaseURL: baseURL, Model: "test"})
+	if err != nil {
+		t.Fatalf("creating engine failed: %v", err)
+	}
+	return e
+}
+
+func status(code int) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) { w.WriteHeader(code) }
+}
+
+func body(content string) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) { w.Write([]byte(content)) }
+}
+
+func TestWithRetry_Ollama(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	testCases := []struct {
+		name     string
+		answers  []func(http.ResponseWriter)
+		attempts int
+		failed   bool
+	}{
+		{name: "first attempt succeeds", attempts: 1},
+		{name: "model loading", answers: []func(http.ResponseWriter){status(500), status(503)}, attempts: 3},
+		{name: "rate limited", answers: []func(http.ResponseWriter){status(429)}, attempts: 2},
+		{name: "unparseable answer", answers: []func(http.ResponseWriter){body("not json")}, attempts: 2},
+		{name: "attempts exhausted", answers: []func(http.ResponseWriter){status(502), status(502), status(502)}, attempts: 3, failed: true},
+		{name: "client error is permanent", answers: []func(http.ResponseWriter){status(400)}, attempts: 1, failed: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			server, calls := ollamaStandIn(t, tc.answers...)
+			e := WithRetry(newOllama(t, server.URL), policy)
+
+			// Act
+			doc, err := e.ProcessImage(context.Background(), bytes.NewReader([]byte("image")))
+
+			// Assert
+			if int(calls.Load()) != tc.attempts {
+				t.Errorf("expected %d calls, got %d", tc.attempts, calls.Load())
+			}
+			if tc.failed {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if doc.Attempts != tc.attempts || doc.Fields == nil || doc.Fields.Name != "Sandra" {
+				t.Errorf("unexpected document: %+v", doc)
+			}
+		})
+	}
+}
+
+func TestWithRetry_ConnectionRefused(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	e := WithRetry(newOllama(t, url), RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	// Act
+	_, err := e.ProcessImage(context.Background(), bytes.NewReader([]byte("image")))
+
+	// Assert
+	if err == nil || !engine.IsTransient(err) {
+		t.Fatalf("expected a transient error, got %v", err)
+	}
+}
+
+func TestWithRetry_StopsWhenCancelled(t *testing.T) {
+	// Arrange
+	server, calls := ollamaStandIn(t, status(503), status(503))
+	e := WithRetry(newOllama(t, server.URL), RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// Act
+	_, err := e.ProcessImage(ctx, bytes.NewReader([]byte("image")))
+
+	// Assert
+	if !errors.Is(err, context.DeadlineExceeded) || calls.Load() != 1 {
+		t.Errorf("expected to give up during the backoff after 1 call, got %d calls and %v", calls.Load(), err)
+	}
+}
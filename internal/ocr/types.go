@@ -1,14 +1,31 @@
 package ocr
 
-import "encoding/json"
+import (
+	"context"
+	"io"
+	"ocr-tool/internal/ocr/engine"
+)
+
+// The result model lives with the engines that produce it.
+type (
+	Document = engine.Document
+	Fields   = engine.Fields
+	Timings  = engine.Timings
+)
 
 type OCRResult struct {
-	Json     json.RawMessage
-	Filename string
+	Document Document
+	Source   string  // name of the input the image was derived from
+	Page     int     // 1-based page within Source, 0 for single images
+	Rotation float64 // degrees counter-clockwise applied by preprocessing
+	Variant  string  // preprocessing variant the result was kept from
 	Error    error
 }
 
+// OCREngine recognizes the text of an encoded image (PNG, JPEG, TIFF, ...).
+// ProcessImage returns an error wrapping ctx.Err() once ctx ends, without
+// waiting for the engine.
 type OCREngine interface {
-	ProcessImage(imagePath string) (json.RawMessage, error)
+	ProcessImage(ctx context.Context, image io.Reader) (Document, error)
 	Close() error
 }
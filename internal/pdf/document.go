@@ -0,0 +1,343 @@
+// Package pdf is a minimal, pure-Go PDF reader that locates the images
+// embedded in each page. It is aimed at scanned documents, where every page
+// is one or more image XObjects, and does not render text or vector content.
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+var (
+	ErrEncrypted = errors.New("encrypted PDFs are not supported")
+	ErrNoImages  = errors.New("page has no images")
+)
+
+// maxFormDepth bounds the recursion into form XObjects nested in each other.
+const maxFormDepth = 8
+
+// maxImagePixels bounds the size of a decoded image, 16384 x 16384, which is
+// well above an A3 page scanned at 1200 dpi.
+const maxImagePixels = 1 << 28
+
+// maxStreamSize bounds a decompressed stream to an image of maxImagePixels
+// with four 8-bit components. A variable so that tests can lower it.
+var maxStreamSize = 4 * maxImagePixels
+
+var errStreamTooLarge = errors.New("decompressed stream too large")
+
+var objHeader = regexp.MustCompile(`(\d+)[ \t\r\n\f\x00]+(\d+)[ \t\r\n\f\x00]+obj`)
+
+type Document struct {
+	data    []byte
+	objects map[int]any
+	pages   []dict
+}
+
+// Signature reports whether data starts like a PDF file.
+func Signature(data []byte) bool {
+	head := data[:min(len(data), 1024)]
+	return bytes.Contains(head, []byte("%PDF-"))
+}
+
+// ReadFile opens the PDF document stored at path.
+func ReadFile(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Open(data)
+}
+
+// Open parses a PDF document. Objects are located by scanning the whole file
+// rather than trusting the cross-reference table, which is frequently broken
+// in scanner output.
+func Open(data []byte) (*Document, error) {
+	if !Signature(data) {
+		return nil, fmt.Errorf("missing PDF header")
+	}
+
+	d := &Document{data: data, objects: make(map[int]any)}
+	trailer := d.scanObjects()
+	d.loadObjectStreams()
+
+	if _, ok := trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+
+	root := d.dictOf(trailer["Root"])
+	if root == nil {
+		root = d.findCatalog()
+	}
+	if root == nil {
+		return nil, fmt.Errorf("missing document catalog")
+	}
+
+	d.collectPages(root["Pages"], nil, make(map[ref]bool))
+	if len(d.pages) == 0 {
+		return nil, fmt.Errorf("document has no pages")
+	}
+	return d, nil
+}
+
+func (d *Document) NumPages() int {
+	return len(d.pages)
+}
+
+// PageImage decodes the largest image drawn by the 1-based page. Scanned
+// pages normally hold a single image, smaller ones are logos or thumbnails.
+func (d *Document) PageImage(page int) (image.Image, error) {
+	if page < 1 || page > len(d.pages) {
+		return nil, fmt.Errorf("page %d out of range (1-%d)", page, len(d.pages))
+	}
+
+	var images []*stream
+	d.collectImages(d.dictOf(d.pages[page-1]["Resources"]), 0, make(map[*stream]bool), &images)
+	if len(images) == 0 {
+		return nil, ErrNoImages
+	}
+
+	largest := images[0]
+	for _, img := range images[1:] {
+		if d.area(img) > d.area(largest) {
+			largest = img
+		}
+	}
+
+	decoded, err := d.decodeImage(largest)
+	if err != nil {
+		return nil, fmt.Errorf("decoding image on page %d: %w", page, err)
+	}
+	return decoded, nil
+}
+
+func (d *Document) area(s *stream) int {
+	return d.intOf(s.dict["Width"], 0) * d.intOf(s.dict["Height"], 0)
+}
+
+// scanObjects indexes every "N G obj" in file order, later definitions
+// replacing earlier ones as incremental updates do, and returns the most
+// recent trailer dictionary.
+func (d *Document) scanObjects() dict {
+	var trailer dict
+	trailerAt, skipUntil := -1, 0
+
+	for _, m := range objHeader.FindAllSubmatchIndex(d.data, -1) {
+		if m[0] < skipUntil || (m[0] > 0 && d.data[m[0]-1] >= '0' && d.data[m[0]-1] <= '9') {
+			continue
+		}
+		num, _ := strconv.Atoi(string(d.data[m[2]:m[3]]))
+
+		value, end, err := d.parseIndirect(m[1])
+		if err != nil {
+			continue
+		}
+		d.objects[num] = value
+		skipUntil = end
+
+		if s, ok := value.(*stream); ok && s.dict["Type"] == name("XRef") {
+			trailer, trailerAt = s.dict, m[0]
+		}
+	}
+
+	if at := bytes.LastIndex(d.data, []byte("trailer")); at > trailerAt {
+		p := &parser{data: d.data, pos: at + len("trailer")}
+		if value, err := p.readObject(); err == nil {
+			if t, ok := value.(dict); ok {
+				trailer = t
+			}
+		}
+	}
+	return trailer
+}
+
+// parseIndirect reads the object body starting at offset, just after "obj",
+// and returns it with the offset where it ends.
+func (d *Document) parseIndirect(offset int) (any, int, error) {
+	p := &parser{data: d.data, pos: offset}
+	value, err := p.readObject()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dic, ok := value.(dict)
+	if !ok {
+		return value, p.pos, nil
+	}
+	p.skipSpace()
+	if !p.hasPrefix("stream") {
+		return dic, p.pos, nil
+	}
+
+	p.pos += len("stream")
+	if !p.eof() && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if !p.eof() && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+
+	// Trust /Length only when it is direct and lands on endstream
+	if length, ok := dic["Length"].(int); ok && length >= 0 && start+length <= len(d.data) {
+		after := &parser{data: d.data, pos: start + length}
+		after.skipSpace()
+		if after.hasPrefix("endstream") {
+			return &stream{dict: dic, data: d.data[start : start+length]}, after.pos + len("endstream"), nil
+		}
+	}
+
+	idx := bytes.Index(d.data[start:], []byte("endstream"))
+	if idx < 0 {
+		return nil, 0, fmt.Errorf("unterminated stream at offset %d", start)
+	}
+	end := start + idx
+	if end > start && d.data[end-1] == '\n' {
+		end--
+	}
+	if end > start && d.data[end-1] == '\r' {
+		end--
+	}
+	return &stream{dict: dic, data: d.data[start:end]}, start + idx + len("endstream"), nil
+}
+
+// loadObjectStreams indexes the objects compressed into object streams.
+// Objects defined directly in the file take precedence.
+func (d *Document) loadObjectStreams() {
+	var objStms []*stream
+	for _, value := range d.objects {
+		if s, ok := value.(*stream); ok && s.dict["Type"] == name("ObjStm") {
+			objStms = append(objStms, s)
+		}
+	}
+
+	for _, s := range objStms {
+		content, imageFilter, _, err := d.decodeData(s)
+		if err != nil || imageFilter != "" {
+			continue
+		}
+		n, first := d.intOf(s.dict["N"], 0), d.intOf(s.dict["First"], 0)
+
+		header := &parser{data: content}
+		for i := 0; i < n; i++ {
+			numValue, err1 := header.readObject()
+			offValue, err2 := header.readObject()
+			num, ok1 := numValue.(int)
+			off, ok2 := offValue.(int)
+			if err1 != nil || err2 != nil || !ok1 || !ok2 {
+				break
+			}
+			if _, exists := d.objects[num]; exists || first < 0 || off < 0 || first+off >= len(content) {
+				continue
+			}
+			body := &parser{data: content, pos: first + off}
+			if value, err := body.readObject(); err == nil {
+				d.objects[num] = value
+			}
+		}
+	}
+}
+
+func (d *Document) findCatalog() dict {
+	for _, value := range d.objects {
+		if dic, ok := value.(dict); ok && dic["Type"] == name("Catalog") {
+			return dic
+		}
+	}
+	return nil
+}
+
+// collectPages flattens the page tree, resolving inherited resources.
+func (d *Document) collectPages(node any, inherited any, visited map[ref]bool) {
+	if r, ok := node.(ref); ok {
+		if visited[r] {
+			return
+		}
+		visited[r] = true
+	}
+	dic := d.dictOf(node)
+	if dic == nil {
+		return
+	}
+
+	resources := inherited
+	if res, ok := dic["Resources"]; ok {
+		resources = res
+	}
+
+	if kids, ok := d.resolve(dic["Kids"]).(array); ok && dic["Type"] != name("Page") {
+		for _, kid := range kids {
+			d.collectPages(kid, resources, visited)
+		}
+		return
+	}
+
+	page := dict{"Resources": resources}
+	d.pages = append(d.pages, page)
+}
+
+// collectImages gathers the image XObjects of a resource dictionary,
+// descending into form XObjects.
+func (d *Document) collectImages(resources dict, depth int, seen map[*stream]bool, images *[]*stream) {
+	if resources == nil || depth > maxFormDepth {
+		return
+	}
+
+	for _, value := range d.dictOf(resources["XObject"]) {
+		s, ok := d.resolve(value).(*stream)
+		if !ok || seen[s] {
+			continue
+		}
+		seen[s] = true
+
+		switch s.dict["Subtype"] {
+		case name("Image"):
+			*images = append(*images, s)
+		case name("Form"):
+			d.collectImages(d.dictOf(s.dict["Resources"]), depth+1, seen, images)
+		}
+	}
+}
+
+func (d *Document) resolve(value any) any {
+	for i := 0; i < 32; i++ {
+		r, ok := value.(ref)
+		if !ok {
+			return value
+		}
+		value = d.objects[r.num]
+	}
+	return nil
+}
+
+func (d *Document) dictOf(value any) dict {
+	switch v := d.resolve(value).(type) {
+	case dict:
+		return v
+	case *stream:
+		return v.dict
+	default:
+		return nil
+	}
+}
+
+func (d *Document) intOf(value any, def int) int {
+	switch v := d.resolve(value).(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return def
+	}
+}
+
+func (d *Document) boolOf(value any) bool {
+	v, _ := d.resolve(value).(bool)
+	return v
+}
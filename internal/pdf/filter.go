@@ -0,0 +1,256 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/lzw"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	tifflzw "golang.org/x/image/tiff/lzw"
+)
+
+// Image filters are decoded straight into an image rather than to bytes and
+// must be the last filter of a stream.
+var imageFilters = map[name]bool{
+	"DCTDecode": true, "DCT": true,
+	"CCITTFaxDecode": true, "CCF": true,
+	"JPXDecode": true, "JBIG2Decode": true,
+}
+
+// decodeData applies the generic filters of the stream in order. It stops at
+// the first image filter and returns it with its parameters, undecoded.
+func (d *Document) decodeData(s *stream) ([]byte, name, dict, error) {
+	var filters []any
+	var parms []any
+	switch f := d.resolve(s.dict["Filter"]).(type) {
+	case name:
+		filters = []any{f}
+		parms = []any{s.dict["DecodeParms"]}
+	case array:
+		filters = f
+		if p, ok := d.resolve(s.dict["DecodeParms"]).(array); ok {
+			parms = p
+		}
+	}
+
+	data := s.data
+	for i, f := range filters {
+		filter, _ := d.resolve(f).(name)
+		var params dict
+		if i < len(parms) {
+			params = d.dictOf(parms[i])
+		}
+
+		if imageFilters[filter] {
+			return data, filter, params, nil
+		}
+
+		var err error
+		if data, err = d.applyFilter(filter, data, params); err != nil {
+			return nil, "", nil, fmt.Errorf("%s: %w", filter, err)
+		}
+	}
+	return data, "", nil, nil
+}
+
+func (d *Document) applyFilter(filter name, data []byte, params dict) ([]byte, error) {
+	switch filter {
+	case "FlateDecode", "Fl":
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		out, err := readStream(zr)
+		if errors.Is(err, errStreamTooLarge) || (err != nil && len(out) == 0) {
+			return nil, err
+		}
+		// Truncated streams are common, keep what was inflated
+		return d.unpredict(out, params)
+	case "LZWDecode", "LZW":
+		var lr io.ReadCloser
+		if d.intOf(params["EarlyChange"], 1) == 1 {
+			lr = tifflzw.NewReader(bytes.NewReader(data), tifflzw.MSB, 8)
+		} else {
+			lr = lzw.NewReader(bytes.NewReader(data), lzw.MSB, 8)
+		}
+		defer lr.Close()
+		out, err := readStream(lr)
+		if errors.Is(err, errStreamTooLarge) || (err != nil && len(out) == 0) {
+			return nil, err
+		}
+		return d.unpredict(out, params)
+	case "ASCIIHexDecode", "AHx":
+		return decodeASCIIHex(data)
+	case "ASCII85Decode", "A85":
+		return decodeASCII85(data)
+	case "RunLengthDecode", "RL":
+		return decodeRunLength(data)
+	default:
+		return nil, fmt.Errorf("unsupported filter")
+	}
+}
+
+// readStream reads a decompressed stream, failing beyond maxStreamSize
+// rather than inflating a small file to gigabytes.
+func readStream(r io.Reader) ([]byte, error) {
+	out, err := io.ReadAll(io.LimitReader(r, int64(maxStreamSize)+1))
+	if len(out) > maxStreamSize {
+		return nil, errStreamTooLarge
+	}
+	return out, err
+}
+
+func decodeASCIIHex(data []byte) ([]byte, error) {
+	var digits []byte
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if !isWhite(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	_, err := hex.Decode(out, digits)
+	return out, err
+}
+
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if end := bytes.Index(data, []byte("~>")); end >= 0 {
+		data = data[:end]
+	}
+	out := make([]byte, 4*len(data)/5+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	return out[:n], err
+}
+
+func decodeRunLength(data []byte) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(data); {
+		if len(out) > maxStreamSize {
+			return nil, errStreamTooLarge
+		}
+		length := int(data[i])
+		i++
+		switch {
+		case length == 128:
+			return out, nil
+		case length < 128:
+			end := min(i+length+1, len(data))
+			out = append(out, data[i:end]...)
+			i = end
+		default:
+			if i < len(data) {
+				out = append(out, bytes.Repeat(data[i:i+1], 257-length)...)
+			}
+			i++
+		}
+	}
+	if len(out) > maxStreamSize {
+		return nil, errStreamTooLarge
+	}
+	return out, nil
+}
+
+// unpredict reverses the PNG and TIFF predictors used with Flate and LZW.
+func (d *Document) unpredict(data []byte, params dict) ([]byte, error) {
+	predictor := d.intOf(params["Predictor"], 1)
+	if predictor == 1 {
+		return data, nil
+	}
+
+	colors := d.intOf(params["Colors"], 1)
+	bpc := d.intOf(params["BitsPerComponent"], 8)
+	columns := d.intOf(params["Columns"], 1)
+	if colors <= 0 || colors > 32 || bpc <= 0 || bpc > 16 || columns <= 0 || columns > maxImagePixels/colors {
+		return nil, fmt.Errorf("invalid predictor parameters")
+	}
+	bpp := max(1, (colors*bpc+7)/8)
+	rowLen := (colors*bpc*columns + 7) / 8
+
+	if predictor == 2 {
+		if bpc != 8 {
+			return nil, fmt.Errorf("TIFF predictor with %d bits per component is not supported", bpc)
+		}
+		out := append([]byte(nil), data...)
+		for row := 0; row+rowLen <= len(out); row += rowLen {
+			for i := row + colors; i < row+rowLen; i++ {
+				out[i] += out[i-colors]
+			}
+		}
+		return out, nil
+	}
+
+	// PNG predictors: every row starts with its own filter type byte
+	var out []byte
+	prev := make([]byte, rowLen)
+	for row := 0; row+1 <= len(data); row += rowLen + 1 {
+		end := min(row+1+rowLen, len(data))
+		cur := make([]byte, rowLen)
+		copy(cur, data[row+1:end])
+
+		switch data[row] {
+		case 0:
+		case 1:
+			for i := bpp; i < rowLen; i++ {
+				cur[i] += cur[i-bpp]
+			}
+		case 2:
+			for i := range cur {
+				cur[i] += prev[i]
+			}
+		case 3:
+			for i := range cur {
+				var left byte
+				if i >= bpp {
+					left = cur[i-bpp]
+				}
+				cur[i] += byte((int(left) + int(prev[i])) / 2)
+			}
+		case 4:
+			for i := range cur {
+				var left, upLeft byte
+				if i >= bpp {
+					left, upLeft = cur[i-bpp], prev[i-bpp]
+				}
+				cur[i] += paeth(left, prev[i], upLeft)
+			}
+		default:
+			return nil, fmt.Errorf("invalid PNG predictor %d", data[row])
+		}
+
+		out = append(out, cur...)
+		prev = cur
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
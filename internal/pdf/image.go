@@ -0,0 +1,357 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+
+	"golang.org/x/image/ccitt"
+)
+
+// colorSpace describes how image samples map to colors.
+type colorSpace struct {
+	components  int    // components per sample
+	base        int    // components per palette entry, Indexed only
+	palette     []byte // Indexed lookup table
+	subtractive bool   // Separation: a full tint is dark
+}
+
+func (d *Document) decodeImage(s *stream) (image.Image, error) {
+	width := d.intOf(s.dict["Width"], 0)
+	height := d.intOf(s.dict["Height"], 0)
+	if err := checkSize(width, height); err != nil {
+		return nil, err
+	}
+
+	data, filter, params, err := d.decodeData(s)
+	if err != nil {
+		return nil, err
+	}
+	decode := d.numbers(s.dict["Decode"])
+	mask := d.boolOf(s.dict["ImageMask"])
+
+	switch filter {
+	case "DCTDecode", "DCT":
+		// The JPEG header may disagree with /Width and /Height
+		if config, err := jpeg.DecodeConfig(bytes.NewReader(data)); err == nil {
+			if err := checkSize(config.Width, config.Height); err != nil {
+				return nil, fmt.Errorf("DCTDecode: %w", err)
+			}
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("DCTDecode: %w", err)
+		}
+		if isInvertedDecode(decode) {
+			return invert(img), nil
+		}
+		return img, nil
+	case "CCITTFaxDecode", "CCF":
+		return d.decodeCCITT(data, params, width, height, decode)
+	case "":
+	default:
+		return nil, fmt.Errorf("%s: unsupported image filter", filter)
+	}
+
+	if mask {
+		return decodeMask(data, width, height, decode), nil
+	}
+
+	cs, err := d.colorSpace(s.dict["ColorSpace"], 0)
+	if err != nil {
+		return nil, err
+	}
+	bpc := d.intOf(s.dict["BitsPerComponent"], 8)
+	switch bpc {
+	case 1, 2, 4, 8, 16:
+	default:
+		return nil, fmt.Errorf("unsupported bits per component %d", bpc)
+	}
+	return decodeSamples(data, width, height, bpc, cs, decode), nil
+}
+
+func (d *Document) decodeCCITT(data []byte, params dict, width, height int, decode []float64) (image.Image, error) {
+	k := d.intOf(params["K"], 0)
+	columns := d.intOf(params["Columns"], 1728)
+	rows := d.intOf(params["Rows"], height)
+	if rows <= 0 {
+		rows = height
+	}
+	if err := checkSize(columns, rows); err != nil {
+		return nil, fmt.Errorf("CCITTFaxDecode: %w", err)
+	}
+
+	var subFormat ccitt.SubFormat
+	switch {
+	case k < 0:
+		subFormat = ccitt.Group4
+	case k == 0:
+		subFormat = ccitt.Group3
+	default:
+		return nil, fmt.Errorf("CCITTFaxDecode: mixed 1D/2D encoding (K=%d) is not supported", k)
+	}
+
+	dst := image.NewGray(image.Rect(0, 0, columns, rows))
+	opts := &ccitt.Options{
+		Align:  d.boolOf(params["EncodedByteAlign"]),
+		Invert: d.boolOf(params["BlackIs1"]),
+	}
+	// Encoders often omit the end-of-block marker, rows decoded up to the end
+	// of the data are kept
+	err := ccitt.DecodeIntoGray(dst, bytes.NewReader(data), ccitt.MSB, subFormat, opts)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("CCITTFaxDecode: %w", err)
+	}
+	if isInvertedDecode(decode) {
+		return invert(dst), nil
+	}
+	return dst, nil
+}
+
+// checkSize rejects empty images and those above maxImagePixels before any
+// pixel is allocated.
+func checkSize(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	if width > maxImagePixels/height {
+		return fmt.Errorf("image size %dx%d exceeds %d pixels", width, height, maxImagePixels)
+	}
+	return nil
+}
+
+func (d *Document) colorSpace(value any, depth int) (colorSpace, error) {
+	if depth > 4 {
+		return colorSpace{}, fmt.Errorf("color space nested too deeply")
+	}
+
+	switch v := d.resolve(value).(type) {
+	case name:
+		switch v {
+		case "DeviceGray", "G", "CalGray":
+			return colorSpace{components: 1}, nil
+		case "DeviceRGB", "RGB", "CalRGB":
+			return colorSpace{components: 3}, nil
+		case "DeviceCMYK", "CMYK":
+			return colorSpace{components: 4}, nil
+		}
+		return colorSpace{}, fmt.Errorf("unsupported color space %s", v)
+	case array:
+		if len(v) == 0 {
+			break
+		}
+		family, _ := d.resolve(v[0]).(name)
+		switch family {
+		case "CalGray":
+			return colorSpace{components: 1}, nil
+		case "CalRGB", "Lab":
+			return colorSpace{components: 3}, nil
+		case "ICCBased":
+			if len(v) > 1 {
+				if n := d.intOf(d.dictOf(v[1])["N"], 0); n == 1 || n == 3 || n == 4 {
+					return colorSpace{components: n}, nil
+				}
+			}
+		case "Separation":
+			return colorSpace{components: 1, subtractive: true}, nil
+		case "Indexed", "I":
+			if len(v) < 4 {
+				break
+			}
+			base, err := d.colorSpace(v[1], depth+1)
+			if err != nil {
+				return colorSpace{}, err
+			}
+			palette, err := d.bytesOf(v[3])
+			if err != nil {
+				return colorSpace{}, fmt.Errorf("reading palette: %w", err)
+			}
+			return colorSpace{components: 1, base: base.components, palette: palette}, nil
+		}
+		return colorSpace{}, fmt.Errorf("unsupported color space %s", family)
+	case nil:
+		return colorSpace{components: 1}, nil
+	}
+	return colorSpace{}, fmt.Errorf("invalid color space")
+}
+
+func (d *Document) bytesOf(value any) ([]byte, error) {
+	switch v := d.resolve(value).(type) {
+	case string:
+		return []byte(v), nil
+	case *stream:
+		data, filter, _, err := d.decodeData(v)
+		if err != nil {
+			return nil, err
+		}
+		if filter != "" {
+			return nil, fmt.Errorf("unexpected image filter %s", filter)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("expected string or stream")
+	}
+}
+
+func (d *Document) numbers(value any) []float64 {
+	arr, _ := d.resolve(value).(array)
+	out := make([]float64, 0, len(arr))
+	for _, item := range arr {
+		switch v := d.resolve(item).(type) {
+		case int:
+			out = append(out, float64(v))
+		case float64:
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// isInvertedDecode reports whether the Decode array is [1 0 1 0 ...].
+func isInvertedDecode(decode []float64) bool {
+	if len(decode) == 0 || len(decode)%2 != 0 {
+		return false
+	}
+	for i := 0; i < len(decode); i += 2 {
+		if decode[i] != 1 || decode[i+1] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sampleReader unpacks samples of 1 to 16 bits from byte-aligned rows.
+type sampleReader struct {
+	data     []byte
+	bpc      int
+	rowBytes int
+}
+
+func (r sampleReader) at(row, index int) int {
+	base := row * r.rowBytes
+	switch r.bpc {
+	case 8:
+		return int(r.byteAt(base + index))
+	case 16:
+		return int(r.byteAt(base+index*2))<<8 | int(r.byteAt(base+index*2+1))
+	default:
+		bit := index * r.bpc
+		b := r.byteAt(base + bit/8)
+		shift := 8 - r.bpc - bit%8
+		return int(b>>shift) & (1<<r.bpc - 1)
+	}
+}
+
+func (r sampleReader) byteAt(i int) byte {
+	// Truncated image data is padded with zeros
+	if i < len(r.data) {
+		return r.data[i]
+	}
+	return 0
+}
+
+// decodeMask renders a stencil mask: painted samples black, others white.
+func decodeMask(data []byte, width, height int, decode []float64) image.Image {
+	r := sampleReader{data: data, bpc: 1, rowBytes: (width + 7) / 8}
+	paint := 0
+	if isInvertedDecode(decode) {
+		paint = 1
+	}
+
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if r.at(y, x) != paint {
+				img.Pix[y*img.Stride+x] = 0xff
+			}
+		}
+	}
+	return img
+}
+
+func decodeSamples(data []byte, width, height, bpc int, cs colorSpace, decode []float64) image.Image {
+	r := sampleReader{data: data, bpc: bpc, rowBytes: (width*cs.components*bpc + 7) / 8}
+	maxValue := float64(int(1)<<bpc - 1)
+
+	// component maps a raw sample to [0, 255] following the Decode array
+	component := func(raw, c int) uint8 {
+		lo, hi := 0.0, 1.0
+		if len(decode) >= 2*(c+1) {
+			lo, hi = decode[2*c], decode[2*c+1]
+		}
+		v := lo + float64(raw)*(hi-lo)/maxValue
+		return uint8(min(max(v, 0), 1)*255 + 0.5)
+	}
+
+	if cs.components == 1 && cs.palette == nil {
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				g := component(r.at(y, x), 0)
+				if cs.subtractive {
+					g = 255 - g
+				}
+				img.Pix[y*img.Stride+x] = g
+			}
+		}
+		return img
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var c color.RGBA
+			switch {
+			case cs.palette != nil:
+				c = paletteColor(cs, r.at(y, x))
+			case cs.components == 3:
+				c = color.RGBA{component(r.at(y, x*3), 0), component(r.at(y, x*3+1), 1), component(r.at(y, x*3+2), 2), 0xff}
+			default:
+				cr, cg, cb := color.CMYKToRGB(component(r.at(y, x*4), 0), component(r.at(y, x*4+1), 1), component(r.at(y, x*4+2), 2), component(r.at(y, x*4+3), 3))
+				c = color.RGBA{cr, cg, cb, 0xff}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func paletteColor(cs colorSpace, index int) color.RGBA {
+	entry := make([]byte, cs.base)
+	if start := index * cs.base; start+cs.base <= len(cs.palette) {
+		copy(entry, cs.palette[start:start+cs.base])
+	}
+
+	switch cs.base {
+	case 1:
+		return color.RGBA{entry[0], entry[0], entry[0], 0xff}
+	case 3:
+		return color.RGBA{entry[0], entry[1], entry[2], 0xff}
+	default:
+		r, g, b := color.CMYKToRGB(entry[0], entry[1], entry[2], entry[3])
+		return color.RGBA{r, g, b, 0xff}
+	}
+}
+
+func invert(img image.Image) image.Image {
+	if gray, ok := img.(*image.Gray); ok {
+		for i := range gray.Pix {
+			gray.Pix[i] = 255 - gray.Pix[i]
+		}
+		return gray
+	}
+
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		rgba.Pix[i] = 255 - rgba.Pix[i]
+		rgba.Pix[i+1] = 255 - rgba.Pix[i+1]
+		rgba.Pix[i+2] = 255 - rgba.Pix[i+2]
+	}
+	return rgba
+}
@@ -0,0 +1,316 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// PDF object model. Integers are int, reals float64, strings string,
+// booleans bool and null nil.
+type (
+	name  string
+	dict  map[name]any
+	array []any
+	ref   struct{ num, gen int }
+)
+
+type stream struct {
+	dict dict
+	data []byte // raw, still encoded
+}
+
+// maxNesting bounds how deeply arrays and dictionaries may nest.
+const maxNesting = 256
+
+type parser struct {
+	data  []byte
+	pos   int
+	depth int // arrays and dictionaries being read
+}
+
+func isWhite(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isDelim(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() {
+		c := p.data[p.pos]
+		switch {
+		case isWhite(c):
+			p.pos++
+		case c == '%':
+			for !p.eof() && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) hasPrefix(s string) bool {
+	return bytes.HasPrefix(p.data[p.pos:], []byte(s))
+}
+
+// token reads a run of regular characters.
+func (p *parser) token() string {
+	start := p.pos
+	for !p.eof() && !isWhite(p.data[p.pos]) && !isDelim(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *parser) readObject() (any, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		return p.readName(), nil
+	case p.hasPrefix("<<"):
+		p.pos += 2
+		return p.readDict()
+	case c == '<':
+		p.pos++
+		return p.readHexString()
+	case c == '(':
+		p.pos++
+		return p.readLiteralString()
+	case c == '[':
+		p.pos++
+		return p.readArray()
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.readNumberOrRef()
+	}
+
+	start := p.pos
+	switch tok := p.token(); tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		return nil, fmt.Errorf("unexpected character %q at offset %d", p.data[p.pos], p.pos)
+	default:
+		return nil, fmt.Errorf("unexpected keyword %q at offset %d", tok, start)
+	}
+}
+
+func (p *parser) readName() name {
+	raw := p.token()
+	if !bytes.Contains([]byte(raw), []byte("#")) {
+		return name(raw)
+	}
+
+	var out []byte
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if v, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+				out = append(out, byte(v))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, raw[i])
+	}
+	return name(out)
+}
+
+func (p *parser) readDict() (dict, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer p.unnest()
+
+	d := dict{}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, fmt.Errorf("unterminated dictionary")
+		}
+		if p.hasPrefix(">>") {
+			p.pos += 2
+			return d, nil
+		}
+
+		key, err := p.readObject()
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.(name)
+		if !ok {
+			return nil, fmt.Errorf("dictionary key %v is not a name", key)
+		}
+		value, err := p.readObject()
+		if err != nil {
+			return nil, err
+		}
+		d[k] = value
+	}
+}
+
+func (p *parser) readArray() (array, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer p.unnest()
+
+	var a array
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		if p.data[p.pos] == ']' {
+			p.pos++
+			return a, nil
+		}
+
+		value, err := p.readObject()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, value)
+	}
+}
+
+// nest enters an array or dictionary, failing past maxNesting levels.
+func (p *parser) nest() error {
+	if p.depth >= maxNesting {
+		return fmt.Errorf("objects nested too deeply at offset %d", p.pos)
+	}
+	p.depth++
+	return nil
+}
+
+func (p *parser) unnest() {
+	p.depth--
+}
+
+func (p *parser) readHexString() (string, error) {
+	var out []byte
+	var digits []byte
+	for ; !p.eof(); p.pos++ {
+		c := p.data[p.pos]
+		if c == '>' {
+			p.pos++
+			if len(digits) == 1 {
+				digits = append(digits, '0')
+			}
+			if len(digits) == 2 {
+				v, _ := strconv.ParseUint(string(digits), 16, 8)
+				out = append(out, byte(v))
+			}
+			return string(out), nil
+		}
+		if isWhite(c) {
+			continue
+		}
+		digits = append(digits, c)
+		if len(digits) == 2 {
+			v, err := strconv.ParseUint(string(digits), 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid hex string at offset %d", p.pos)
+			}
+			out = append(out, byte(v))
+			digits = digits[:0]
+		}
+	}
+	return "", fmt.Errorf("unterminated hex string")
+}
+
+func (p *parser) readLiteralString() (string, error) {
+	var out []byte
+	depth := 1
+	for ; !p.eof(); p.pos++ {
+		c := p.data[p.pos]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return string(out), nil
+			}
+		case '\\':
+			p.pos++
+			if p.eof() {
+				return "", fmt.Errorf("unterminated string")
+			}
+			switch e := p.data[p.pos]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if p.pos+1 < len(p.data) && p.data[p.pos+1] == '\n' {
+					p.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					for n := 0; n < 3 && !p.eof() && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; n++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					p.pos--
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+func (p *parser) readNumberOrRef() (any, error) {
+	start := p.pos
+	tok := p.token()
+	n, err := strconv.Atoi(tok)
+	if err != nil {
+		f, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok, start)
+		}
+		return f, nil
+	}
+
+	// Look ahead for "gen R"
+	save := p.pos
+	p.skipSpace()
+	if gen, err := strconv.Atoi(p.token()); err == nil && gen >= 0 {
+		p.skipSpace()
+		if p.hasPrefix("R") && (p.pos+1 == len(p.data) || isWhite(p.data[p.pos+1]) || isDelim(p.data[p.pos+1])) {
+			p.pos++
+			return ref{num: n, gen: gen}, nil
+		}
+	}
+	p.pos = save
+	return n, nil
+}
@@ -0,0 +1,298 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+// buildPDF assembles a document from numbered object bodies, 1-based, where
+// empty bodies are left out. The cross-reference table is omitted since
+// objects are found by scanning.
+func buildPDF(trailer string, objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	for i, body := range objects {
+		if body == "" {
+			continue
+		}
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	fmt.Fprintf(&buf, "trailer\n%s\n%%%%EOF\n", trailer)
+	return buf.Bytes()
+}
+
+func imageObject(dict string, data []byte) string {
+	return fmt.Sprintf("<< /Type /XObject /Subtype /Image %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func pageObject(parent int, image int) string {
+	if image == 0 {
+		return fmt.Sprintf("<< /Type /Page /Parent %d 0 R /Resources << >> >>", parent)
+	}
+	return fmt.Sprintf("<< /Type /Page /Parent %d 0 R /Resources << /XObject << /Im0 %d 0 R >> >> >>", parent, image)
+}
+
+func deflate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("compressing failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("compressing failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestPageImage(t *testing.T) {
+	// Arrange
+	gray := make([]byte, 4*3)
+	for i := range gray {
+		gray[i] = byte(i * 20)
+	}
+
+	var jpegBytes bytes.Buffer
+	src := image.NewGray(image.Rect(0, 0, 16, 8))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+	if err := jpeg.Encode(&jpegBytes, src, nil); err != nil {
+		t.Fatalf("encoding JPEG failed: %v", err)
+	}
+
+	// Group 4, 8 pixels wide: a white row followed by two black rows
+	g4 := []byte{0x93, 0x51, 0x70}
+
+	// 1-bit RGB palette: index 0 is red, index 1 is blue
+	indexed := []byte{0x40}
+
+	doc := buildPDF("<< /Root 1 0 R /Size 13 >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R 6 0 R 7 0 R] /Count 5 >>",
+		pageObject(2, 8),
+		pageObject(2, 9),
+		pageObject(2, 10),
+		pageObject(2, 11),
+		pageObject(2, 0),
+		imageObject("/Width 4 /Height 3 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode", deflate(t, gray)),
+		imageObject("/Width 16 /Height 8 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /DCTDecode", jpegBytes.Bytes()),
+		imageObject("/Width 8 /Height 3 /ImageMask true /Filter /CCITTFaxDecode /DecodeParms << /K -1 /Columns 8 /Rows 3 >>", g4),
+		imageObject("/Width 4 /Height 1 /ColorSpace [/Indexed /DeviceRGB 1 <FF00000000FF>] /BitsPerComponent 1 /Filter /ASCIIHexDecode", []byte(fmt.Sprintf("%x>", indexed))),
+	)
+
+	// Act
+	d, err := Open(doc)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("opening PDF failed: %v", err)
+	}
+	if d.NumPages() != 5 {
+		t.Fatalf("expected 5 pages, got %d", d.NumPages())
+	}
+
+	t.Run("flate", func(t *testing.T) {
+		img, err := d.PageImage(1)
+		if err != nil {
+			t.Fatalf("decoding page failed: %v", err)
+		}
+		g, ok := img.(*image.Gray)
+		if !ok {
+			t.Fatalf("expected *image.Gray, got %T", img)
+		}
+		if !bytes.Equal(g.Pix, gray) {
+			t.Errorf("expected pixels %v, got %v", gray, g.Pix)
+		}
+	})
+
+	t.Run("dct", func(t *testing.T) {
+		img, err := d.PageImage(2)
+		if err != nil {
+			t.Fatalf("decoding page failed: %v", err)
+		}
+		if img.Bounds() != src.Bounds() {
+			t.Errorf("expected bounds %v, got %v", src.Bounds(), img.Bounds())
+		}
+	})
+
+	t.Run("ccitt", func(t *testing.T) {
+		img, err := d.PageImage(3)
+		if err != nil {
+			t.Fatalf("decoding page failed: %v", err)
+		}
+		expected := []uint8{0xff, 0x00, 0x00}
+		for y, want := range expected {
+			for x := 0; x < 8; x++ {
+				if got := color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y; got != want {
+					t.Fatalf("pixel (%d,%d): expected %d, got %d", x, y, want, got)
+				}
+			}
+		}
+	})
+
+	t.Run("indexed", func(t *testing.T) {
+		img, err := d.PageImage(4)
+		if err != nil {
+			t.Fatalf("decoding page failed: %v", err)
+		}
+		red, blue := color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{0, 0, 0xff, 0xff}
+		expected := []color.RGBA{red, blue, red, red}
+		for x, want := range expected {
+			if got := color.RGBAModel.Convert(img.At(x, 0)); got != want {
+				t.Errorf("pixel %d: expected %v, got %v", x, want, got)
+			}
+		}
+	})
+
+	t.Run("no_images", func(t *testing.T) {
+		if _, err := d.PageImage(5); !errors.Is(err, ErrNoImages) {
+			t.Errorf("expected ErrNoImages, got %v", err)
+		}
+	})
+}
+
+func TestOpen_Errors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  []byte
+		expected error
+	}{
+		{
+			name: "encrypted",
+			content: buildPDF("<< /Root 1 0 R /Encrypt 3 0 R >>",
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [] /Count 0 >>",
+				"<< /Filter /Standard /V 2 >>",
+			),
+			expected: ErrEncrypted,
+		},
+		{name: "not_a_pdf", content: []byte("just some text")},
+		{name: "no_catalog", content: buildPDF("<< >>", "<< /Foo 1 >>")},
+		{
+			// The catalog is unreachable, the parser must not panic
+			name: "negative_object_stream_offset",
+			content: buildPDF("<< /Root 1 0 R >>",
+				"",
+				fmt.Sprintf("<< /Type /ObjStm /N 1 /First -100 /Length 40 >>\nstream\n%-40s\nendstream", "1 0 << /Type /Catalog /Pages 3 0 R >>"),
+			),
+		},
+		{
+			name: "deep_nesting",
+			content: buildPDF("<< /Root 1 0 R >>",
+				"<< /Type /Catalog /Pages "+strings.Repeat("[", 100000)+" >>",
+			),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			_, err := Open(tc.content)
+
+			// Assert
+			if err == nil {
+				t.Fatal("expected error, got none")
+			}
+			if tc.expected != nil && !errors.Is(err, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestOpen_ObjectStream(t *testing.T) {
+	// Arrange: the catalog and page tree live in a compressed object stream
+	header := "1 0 2 44 "
+	objects := "<< /Type /Catalog /Pages 2 0 R >>           " +
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>"
+	content := deflate(t, []byte(header+objects))
+	doc := buildPDF("<< /Root 1 0 R >>",
+		"",
+		"",
+		pageObject(2, 4),
+		imageObject("/Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8", []byte{0x80}),
+		fmt.Sprintf("<< /Type /ObjStm /N 2 /First %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", len(header), len(content), content),
+	)
+
+	// Act
+	d, err := Open(doc)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("opening PDF failed: %v", err)
+	}
+	if d.NumPages() != 1 {
+		t.Fatalf("expected 1 page, got %d", d.NumPages())
+	}
+	img, err := d.PageImage(1)
+	if err != nil {
+		t.Fatalf("decoding page failed: %v", err)
+	}
+	if got := img.(*image.Gray).Pix[0]; got != 0x80 {
+		t.Errorf("expected pixel 0x80, got %#x", got)
+	}
+}
+
+func TestPageImage_TooLarge(t *testing.T) {
+	// Arrange: the declared size would take 40 GB to decode
+	doc := buildPDF("<< /Root 1 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		pageObject(2, 5),
+		pageObject(2, 6),
+		imageObject("/Width 200000 /Height 200000 /ColorSpace /DeviceGray /BitsPerComponent 8", []byte{0}),
+		imageObject("/Width 8 /Height 1 /ImageMask true /Filter /CCITTFaxDecode /DecodeParms << /K -1 /Columns 200000 /Rows 200000 >>", []byte{0}),
+	)
+	d, err := Open(doc)
+	if err != nil {
+		t.Fatalf("opening PDF failed: %v", err)
+	}
+
+	for page := 1; page <= d.NumPages(); page++ {
+		// Act
+		_, err := d.PageImage(page)
+
+		// Assert
+		if err == nil {
+			t.Errorf("page %d: expected error, got none", page)
+		}
+	}
+}
+
+func TestPageImage_StreamTooLarge(t *testing.T) {
+	// Arrange: 64 KiB of zeros compress to a few hundred bytes
+	defer func(size int) { maxStreamSize = size }(maxStreamSize)
+	maxStreamSize = 1 << 10
+	flate := deflate(t, make([]byte, 1<<16))
+	runLength := bytes.Repeat([]byte{129, 0}, 1<<10)
+	doc := buildPDF("<< /Root 1 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		pageObject(2, 5),
+		pageObject(2, 6),
+		imageObject("/Width 256 /Height 256 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode", flate),
+		imageObject("/Width 256 /Height 256 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /RunLengthDecode", runLength),
+	)
+	d, err := Open(doc)
+	if err != nil {
+		t.Fatalf("opening PDF failed: %v", err)
+	}
+
+	for page := 1; page <= d.NumPages(); page++ {
+		// Act
+		_, err := d.PageImage(page)
+
+		// Assert
+		if !errors.Is(err, errStreamTooLarge) {
+			t.Errorf("page %d: expected %v, got %v", page, errStreamTooLarge, err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package pipeline
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+type archiveFormat string
+
+const (
+	archiveZip   archiveFormat = "zip"
+	archiveTar   archiveFormat = "tar"
+	archiveTarGz archiveFormat = "tar.gz"
+)
+
+// archiveSeparator joins the archive name and the entry path in the name of
+// an archive entry, as in "batch.zip!/scans/page.png".
+const archiveSeparator = "!/"
+
+// maxArchiveEntrySize bounds the entries read into memory, guarding against
+// decompression bombs.
+const maxArchiveEntrySize = 256 << 20
+
+var errEntryTooLarge = fmt.Errorf("archive entry larger than %d MiB", maxArchiveEntrySize>>20)
+
+// detectArchive identifies zip and tar archives from their magic bytes.
+func detectArchive(filePath string) (archiveFormat, bool) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return archiveZip, true
+	case bytes.HasPrefix(head, []byte("\x1f\x8b")):
+		// Only a gzip wrapping a tar is an archive, other .gz files are
+		// inputs of an unsupported format
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return "", false
+		}
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return "", false
+		}
+		defer gz.Close()
+		if _, err := tar.NewReader(gz).Next(); err != nil && !errors.Is(err, io.EOF) {
+			return "", false
+		}
+		return archiveTarGz, true
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return archiveTar, true
+	}
+	return "", false
+}
+
+// walkArchive calls visit with the path and content of every regular file
+// in the archive accepted by its path, streaming entries without unpacking
+// them to disk; the others are never read. Entries that cannot be read are
+// passed with a nil content and the error, a non-nil return means the
+// archive itself is unreadable.
+func walkArchive(filePath string, format archiveFormat, accept func(name string) bool, visit func(name string, content []byte, err error)) error {
+	if format == archiveZip {
+		return walkZip(filePath, accept, visit)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if format == archiveTarGz {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name, ok := entryName(header.Name)
+		if !ok || !accept(name) {
+			continue
+		}
+		content, err := readEntry(tr)
+		visit(name, content, err)
+	}
+}
+
+func walkZip(filePath string, accept func(name string) bool, visit func(name string, content []byte, err error)) error {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, entry := range zr.File {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		name, ok := entryName(entry.Name)
+		if !ok || !accept(name) {
+			continue
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			visit(name, nil, err)
+			continue
+		}
+		content, err := readEntry(rc)
+		rc.Close()
+		visit(name, content, err)
+	}
+	return nil
+}
+
+func readEntry(r io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxArchiveEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxArchiveEntrySize {
+		return nil, errEntryTooLarge
+	}
+	return content, nil
+}
+
+// entryName normalizes the path of an archive entry. Entries climbing out of
+// the archive root with ".." are kept inside it.
+func entryName(name string) (string, bool) {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))[1:]
+	return name, name != ""
+}
+
+// isHiddenPath reports whether any element of the slash separated path is
+// hidden.
+func isHiddenPath(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if isHidden(part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	goimage "image"
+	"image/png"
+	"math/rand"
+	"ocr-tool/internal/data"
+	"ocr-tool/internal/image"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestWalkFiles_Archives(t *testing.T) {
+	// Arrange
+	root := t.TempDir()
+	var pngBytes bytes.Buffer
+	if err := png.Encode(&pngBytes, goimage.NewGray(goimage.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encoding PNG failed: %v", err)
+	}
+	entries := map[string][]byte{
+		"a.png":                     pngBytes.Bytes(),
+		"scans/b.png":               pngBytes.Bytes(),
+		"__MACOSX/scans/._b.png":    []byte("resource fork"),
+		"notes.txt":                 []byte("not an image"),
+		"../escaped/c.png":          pngBytes.Bytes(),
+		"deeper/still/d.png":        pngBytes.Bytes(),
+		".hidden/e.png":             pngBytes.Bytes(),
+		"scans/.DS_Store":           []byte("finder"),
+		"scans/renamed_without_ext": pngBytes.Bytes(),
+	}
+
+	var zipBytes bytes.Buffer
+	zw := zip.NewWriter(&zipBytes)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("creating zip entry failed: %v", err)
+		}
+		w.Write(content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("closing zip failed: %v", err)
+	}
+
+	var tgzBytes bytes.Buffer
+	gw := gzip.NewWriter(&tgzBytes)
+	tw := tar.NewWriter(gw)
+	for _, name := range []string{"a.png", "scans/b.png"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(pngBytes.Len()), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("writing tar header failed: %v", err)
+		}
+		tw.Write(pngBytes.Bytes())
+	}
+	tw.Close()
+	gw.Close()
+
+	// A tar.gz cut short inside its first entry, and a gzip file that is
+	// not a tar
+	var brokenBytes bytes.Buffer
+	gw = gzip.NewWriter(&brokenBytes)
+	tw = tar.NewWriter(gw)
+	noise := make([]byte, 1<<16)
+	rand.New(rand.NewSource(1)).Read(noise)
+	tw.WriteHeader(&tar.Header{Name: "noise.png", Mode: 0644, Size: int64(len(noise)), Typeflag: tar.TypeReg})
+	tw.Write(noise)
+	tw.Close()
+	gw.Close()
+	var plainBytes bytes.Buffer
+	gw = gzip.NewWriter(&plainBytes)
+	gw.Write([]byte("just some text"))
+	gw.Close()
+
+	for name, content := range map[string][]byte{
+		"batch.zip":        zipBytes.Bytes(),
+		"nested/batch.tgz": tgzBytes.Bytes(),
+		"broken.tar.gz":    brokenBytes.Bytes()[:1024],
+		"plain.gz":         plainBytes.Bytes(),
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating directory failed: %v", err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("creating file failed: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		opts     Options
+		expected []string
+		rejected []string
+	}{
+		{
+			name:     "root entries only by default",
+			opts:     Options{},
+			expected: []string{"batch.zip!/a.png"},
+			rejected: []string{"batch.zip!/notes.txt", "plain.gz"},
+		},
+		{
+			name: "recursive",
+			opts: Options{Recursive: true},
+			expected: []string{
+				"batch.zip!/.hidden/e.png",
+				"batch.zip!/a.png",
+				"batch.zip!/deeper/still/d.png",
+				"batch.zip!/escaped/c.png",
+				"batch.zip!/scans/b.png",
+				"batch.zip!/scans/renamed_without_ext",
+				"nested/batch.tgz!/a.png",
+				"nested/batch.tgz!/scans/b.png",
+			},
+			rejected: []string{"batch.zip!/scans/.DS_Store", "batch.zip!/__MACOSX/scans/._b.png"},
+		},
+		{
+			name: "hidden entries skipped on request",
+			opts: Options{Recursive: true, SkipHidden: true, Include: []string{"*.png"}},
+			expected: []string{
+				"batch.zip!/a.png",
+				"batch.zip!/deeper/still/d.png",
+				"batch.zip!/escaped/c.png",
+				"batch.zip!/scans/b.png",
+				"nested/batch.tgz!/a.png",
+				"nested/batch.tgz!/scans/b.png",
+			},
+		},
+		{
+			name:     "max depth counts the archive as a directory",
+			opts:     Options{Recursive: true, MaxDepth: 2, Include: []string{"*.png"}},
+			expected: []string{"batch.zip!/.hidden/e.png", "batch.zip!/a.png", "batch.zip!/escaped/c.png", "batch.zip!/scans/b.png", "nested/batch.tgz!/a.png"},
+		},
+		{
+			name:     "patterns match entry paths",
+			opts:     Options{Recursive: true, Include: []string{"*.zip!/scans/*"}, Exclude: []string{"*_ext"}},
+			expected: []string{"batch.zip!/scans/b.png"},
+		},
+		{
+			name:     "excluded archives are not opened",
+			opts:     Options{Exclude: []string{"*.zip", "*.tar.gz"}},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			files := make(chan inputFile)
+			errChan := make(chan error, 10)
+			outcome := &writeResult[data.ExtractedData]{
+				writes:   make(map[string]data.ExtractedData),
+				failures: make(map[string]error),
+			}
+
+			// Act
+			go func() {
+				defer close(files)
+				walkFiles(context.Background(), root, tc.opts, files, outcome, errChan)
+			}()
+			var actual []string
+			for file := range files {
+				if !bytes.Equal(file.Data, pngBytes.Bytes()) {
+					t.Errorf("expected %s to carry the entry content", file.Name)
+				}
+				actual = append(actual, file.Name)
+			}
+			sort.Strings(actual)
+
+			// Assert
+			if len(errChan) > 0 {
+				t.Fatalf("unexpected walk error: %v", <-errChan)
+			}
+			if !slices.Equal(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+			for _, name := range tc.rejected {
+				if err := outcome.failures[name]; !errors.Is(err, image.ErrUnsupportedFormat) {
+					t.Errorf("expected %s to be rejected as unsupported, got %v", name, err)
+				}
+			}
+			if _, excluded := outcome.failures["broken.tar.gz"]; excluded == slices.Contains(tc.opts.Exclude, "*.tar.gz") {
+				t.Errorf("expected broken.tar.gz to be reported unless excluded, got %v", outcome.failures)
+			}
+		})
+	}
+}
@@ -19,25 +19,26 @@ func extractData(ctx context.Context, ocrChan <-chan ocr.OCRResult, results chan
 	dataExtractor := proc.data
 
 	for ocrOutput := range ocrChan {
-		if ctx.Err() != nil {
-			logger.DebugLog("extractData: context cancelled")
-			return
-		}
-
+		key := inputKey(ocrOutput.Source, ocrOutput.Page)
 		if ocrOutput.Error != nil {
-			logger.DebugLog("extractData: OCR error for %s: %v", ocrOutput.Filename, ocrOutput.Error)
-			results <- result[data.ExtractedData]{path: ocrOutput.Filename, err: ocrOutput.Error}
+			logger.DebugLog("extractData: OCR error for %s: %v", key, ocrOutput.Error)
+			results <- result[data.ExtractedData]{path: key, err: ocrOutput.Error}
 			continue
 		}
 
-		logger.DebugLog("extractData: extracting data from %s", ocrOutput.Filename)
-		res := dataExtractor.ExtractFromJson(ocrOutput.Json, ocrOutput.Filename)
+		logger.DebugLog("extractData: extracting data from %s", key)
+		res := dataExtractor.Extract(ocrOutput.Document, ocrOutput.Source)
 		if res == nil {
-			logger.DebugLog("extractData: extraction returned nil for %s", ocrOutput.Filename)
-			results <- result[data.ExtractedData]{path: ocrOutput.Filename, err: fmt.Errorf("extraction returned nil for %s", ocrOutput.Filename)}
+			logger.DebugLog("extractData: extraction returned nil for %s", key)
+			results <- result[data.ExtractedData]{path: key, err: fmt.Errorf("extraction returned nil for %s", key)}
 			continue
 		}
-		logger.DebugLog("extractData: sending extracted data for %s", ocrOutput.Filename)
-		results <- result[data.ExtractedData]{path: ocrOutput.Filename, data: *res}
+		res.Page = ocrOutput.Page
+		res.Rotation = ocrOutput.Rotation
+		res.Variant = ocrOutput.Variant
+		res.Confidence = ocrOutput.Document.Confidence
+		res.Attempts = ocrOutput.Document.Attempts
+		logger.DebugLog("extractData: sending extracted data for %s", key)
+		results <- result[data.ExtractedData]{path: key, data: *res}
 	}
 }
@@ -1,18 +1,38 @@
 package pipeline
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	goimage "image"
+	"io"
+	"ocr-tool/internal/data"
+	"ocr-tool/internal/image"
 	"ocr-tool/internal/logger"
-	"ocr-tool/internal/ocr"
+	"path"
 )
 
+// enhancedChanItem holds the enhanced images of one input, one per
+// preprocessing variant, PNG encoded in memory so the pipeline never writes
+// next to its inputs.
 type enhancedChanItem struct {
-	Path    string
+	Images  []enhancedImage
+	Source  string
+	Page    int
 	release func()
 }
 
-func enhanceImage(ctx context.Context, files <-chan string, results chan<- enhancedChanItem, throttledChan chan struct{}, errChan chan<- error) {
+type enhancedImage struct {
+	Variant  string
+	Data     []byte
+	Rotation float64
+}
+
+// enhanceImage runs the preprocessing chains on every file. Files failing
+// opts.Quality are reported as failures without reaching the engine. With a
+// debug directory the output of each step is saved there for inspection.
+func enhanceImage(ctx context.Context, files <-chan inputFile, results chan<- enhancedChanItem, throttledChan chan struct{}, opts Options, outcome *writeResult[data.ExtractedData], errChan chan<- error) {
 	ctxClients := ctx.Value(clientsKey)
 	proc, ok := ctxClients.(*Clients)
 	if !ok {
@@ -20,68 +40,120 @@ func enhanceImage(ctx context.Context, files <-chan string, results chan<- enhan
 		errChan <- fmt.Errorf("[enhanceImage]: missing clients in context")
 		return
 	}
-	imageProcessor := proc.image
 
 	for file := range files {
 		if ctx.Err() != nil {
-			logger.DebugLog("[enhanceImage]: context cancelled")
-			return
+			logger.DebugLog("[enhanceImage]: context cancelled, skipping %s", file.Key())
+			outcome.addSkipped(file.Key(), ctx.Err())
+			continue
 		}
 
 		select {
 		case throttledChan <- struct{}{}:
 		case <-ctx.Done():
-			logger.DebugLog("[enhanceImage]: context done before acquiring semaphore for %s", file)
-			return
+			logger.DebugLog("[enhanceImage]: context done before acquiring semaphore for %s", file.Key())
+			outcome.addSkipped(file.Key(), ctx.Err())
+			continue
 		}
 
-		logger.DebugLog("[enhanceImage]: enhancing file %s (in-flight permits=%d)", file, len(throttledChan))
-		processed, err := imageProcessor.EnhanceQuality(file)
+		logger.DebugLog("[enhanceImage]: enhancing file %s (in-flight permits=%d)", file.Key(), len(throttledChan))
+		images, err := enhance(proc.images, file, opts, errChan)
 		if err != nil {
 			<-throttledChan
-			logger.DebugLog("[enhanceImage]: error processing %s: %v", file, err)
-			errChan <- fmt.Errorf("preprocessing image %s: %w", file, err)
+			logger.DebugLog("[enhanceImage]: error processing %s: %v", file.Key(), err)
+			if errors.Is(err, image.ErrLowQuality) {
+				outcome.addFailure(file.Key(), fmt.Errorf("rejected %s before OCR: %w", file.Key(), err))
+				continue
+			}
+			outcome.addFailure(file.Key(), fmt.Errorf("preprocessing image %s: %w", file.Key(), err))
 			continue
 		}
 
 		release := func() { <-throttledChan }
 
-		logger.DebugLog("[enhanceImage]: sending processed file %s", processed)
+		logger.DebugLog("[enhanceImage]: sending processed file %s", file.Key())
 		select {
-		case results <- enhancedChanItem{Path: processed, release: release}:
+		case results <- enhancedChanItem{Images: images, Source: file.Name, Page: file.Page, release: release}:
 		case <-ctx.Done():
-			logger.DebugLog("[enhanceImage]: context done while sending %s", processed)
+			logger.DebugLog("[enhanceImage]: context done while sending %s", file.Key())
 			release()
-			return
+			outcome.addSkipped(file.Key(), ctx.Err())
 		}
 	}
 }
 
-func cleanupImage(ctx context.Context, ocrChan <-chan ocr.OCRResult, errChan chan<- error) {
-	ctxClients := ctx.Value(clientsKey)
-	proc, ok := ctxClients.(*Clients)
-	if !ok {
-		logger.DebugLog("[cleanupImage]: missing clients in context")
-		errChan <- fmt.Errorf("[cleanupImage]: missing clients in context")
-		return
+// enhance checks the quality of the file and runs every variant on it.
+// Variants that fail are dropped, the file only fails when none succeeds.
+// PDF pages are decoded once from the document parsed by splitPages, other
+// files are read and decoded by each variant.
+func enhance(variants []imageVariant, file inputFile, opts Options, errChan chan<- error) ([]enhancedImage, error) {
+	var content []byte
+	var page goimage.Image
+	if file.doc != nil {
+		var err error
+		if page, err = file.doc.PageImage(file.Page); err != nil {
+			return nil, fmt.Errorf("opening image: %w", err)
+		}
+	} else {
+		r, err := file.open()
+		if err != nil {
+			return nil, err
+		}
+		content, err = io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
 	}
-	imageProcessor := proc.image
 
-	for ocrOutput := range ocrChan {
-		if ctx.Err() != nil {
-			logger.DebugLog("[cleanupImage]: context cancelled")
-			return
+	if opts.Quality.Enabled() {
+		img := page
+		if img == nil {
+			var err error
+			if img, err = image.DecodePage(content, file.Page); err != nil {
+				return nil, fmt.Errorf("opening image: %w", err)
+			}
+		}
+		quality := image.AssessQuality(img)
+		logger.DebugLog("[enhanceImage]: quality of %s: %+v", file.Key(), quality)
+		if err := opts.Quality.Check(quality); err != nil {
+			return nil, err
 		}
+	}
 
-		if ocrOutput.Error != nil {
-			logger.DebugLog("[cleanupImage]: skipping file %s due to OCR error", ocrOutput.Filename)
+	var images []enhancedImage
+	var errs []error
+	for _, variant := range variants {
+		var processed image.Enhanced
+		var err error
+		if page != nil {
+			processed, err = variant.processor.EnhanceImage(page, 1)
+		} else {
+			processed, err = variant.processor.EnhanceQuality(bytes.NewReader(content), file.Page)
+		}
+		if err != nil {
+			if variant.name != "" {
+				err = fmt.Errorf("variant %s: %w", variant.name, err)
+			}
+			errs = append(errs, err)
 			continue
 		}
 
-		logger.DebugLog("[cleanupImage]: cleaning up %s", ocrOutput.Filename)
-		if err := imageProcessor.Cleanup(ocrOutput.Filename); err != nil {
-			logger.DebugLog("[cleanupImage]: error cleaning up %s: %v", ocrOutput.Filename, err)
-			errChan <- fmt.Errorf("cleanup image %s: %w", ocrOutput.Filename, err)
+		if opts.DebugDir != "" {
+			name := file.Key()
+			if variant.name != "" {
+				name = path.Join(name, variant.name)
+			}
+			if err := image.WriteDebugImages(opts.DebugDir, name, processed); err != nil {
+				logger.DebugLog("[enhanceImage]: error saving debug images for %s: %v", name, err)
+				errChan <- fmt.Errorf("saving debug images for %s: %w", name, err)
+			}
 		}
+		images = append(images, enhancedImage{Variant: variant.name, Data: processed.Data, Rotation: processed.Rotation})
+	}
+
+	if len(images) == 0 {
+		return nil, errors.Join(errs...)
 	}
+	return images, nil
 }
@@ -1,13 +1,26 @@
 package pipeline
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"ocr-tool/internal/data"
 	"ocr-tool/internal/logger"
 	"ocr-tool/internal/ocr"
+	"ocr-tool/internal/ocr/layout"
+	"os"
+	"path/filepath"
+	"time"
 )
 
-func performOcr(ctx context.Context, preprocessChan <-chan enhancedChanItem, ocrChan chan<- ocr.OCRResult, errChan chan<- error) {
+// performOcr recognizes every enhanced image, giving each engine call at most
+// opts.OCRTimeout. Cancelling the context abandons the image in OCR and
+// drains the remaining ones without processing them so their in-flight
+// permits are released; they are reported as skipped. With a layout
+// directory the layout of every kept result is saved there.
+func performOcr(ctx context.Context, preprocessChan <-chan enhancedChanItem, ocrChan chan<- ocr.OCRResult, opts Options, outcome *writeResult[data.ExtractedData], errChan chan<- error) {
 	ctxClients := ctx.Value(clientsKey)
 	proc, ok := ctxClients.(*Clients)
 	if !ok {
@@ -19,22 +32,111 @@ func performOcr(ctx context.Context, preprocessChan <-chan enhancedChanItem, ocr
 
 	for item := range preprocessChan {
 		if ctx.Err() != nil {
-			logger.DebugLog("[performOcr]: context cancelled")
+			logger.DebugLog("[performOcr]: context cancelled, skipping %s", inputKey(item.Source, item.Page))
+			outcome.addSkipped(inputKey(item.Source, item.Page), ctx.Err())
 			item.release()
-			return
+			continue
 		}
 
-		logger.DebugLog("[performOcr]: processing image %s", item.Path)
-		data, err := ocrEngine.ProcessImage(item.Path)
+		var best *ocr.OCRResult
+		var firstErr error
+		for _, img := range item.Images {
+			if ctx.Err() != nil {
+				break
+			}
 
-		logger.DebugLog("[performOcr]: sending OCR result - %s (err=%v)", data, err)
-		select {
-		case ocrChan <- ocr.OCRResult{Json: data, Filename: item.Path, Error: err}:
-		case <-ctx.Done():
-			logger.DebugLog("[performOcr]: context done while sending OCR result for %s", item.Path)
+			logger.DebugLog("[performOcr]: processing image %s (variant=%q)", inputKey(item.Source, item.Page), img.Variant)
+			doc, err := processImage(ctx, ocrEngine, img.Data, opts.OCRTimeout)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+
+			res := ocr.OCRResult{Document: doc, Source: item.Source, Page: item.Page, Rotation: img.Rotation, Variant: img.Variant}
+			if best == nil || better(&proc.data, res, *best) {
+				best = &res
+			}
+		}
+
+		if best == nil && ctx.Err() != nil {
+			logger.DebugLog("[performOcr]: context cancelled during OCR of %s", inputKey(item.Source, item.Page))
+			outcome.addSkipped(inputKey(item.Source, item.Page), ctx.Err())
 			item.release()
-			return
+			continue
 		}
+
+		res := ocr.OCRResult{Source: item.Source, Page: item.Page, Error: firstErr}
+		if best != nil {
+			res = *best
+			if opts.LayoutDir != "" && res.Document.Layout != nil {
+				if err := writeLayout(opts.LayoutDir, inputKey(item.Source, item.Page), *res.Document.Layout); err != nil {
+					logger.DebugLog("[performOcr]: error saving layout for %s: %v", inputKey(item.Source, item.Page), err)
+					errChan <- fmt.Errorf("saving layout for %s: %w", inputKey(item.Source, item.Page), err)
+				}
+			}
+		}
+
+		// Downstream stages always drain ocrChan, so completed work is never lost
+		logger.DebugLog("[performOcr]: sending OCR result - %q (variant=%q, confidence=%.1f, err=%v)", res.Document.Text, res.Variant, res.Document.Confidence, res.Error)
+		ocrChan <- res
 		item.release()
 	}
 }
+
+// processImage runs the engine with the timeout, 0 for none. Running out of
+// time is reported as ErrTimeout, unlike the cancellation of ctx itself.
+func processImage(ctx context.Context, engine ocr.OCREngine, image []byte, timeout time.Duration) (ocr.Document, error) {
+	callCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	doc, err := engine.ProcessImage(callCtx, bytes.NewReader(image))
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return doc, fmt.Errorf("%w after %s", ErrTimeout, timeout)
+	}
+	return doc, err
+}
+
+// better reports whether candidate should replace current. Engine confidence
+// decides when both results report one, otherwise the result yielding more
+// extracted fields wins. Ties keep current, the earlier variant.
+func better(extractor *data.DataExtractor, candidate, current ocr.OCRResult) bool {
+	if candidate.Document.Confidence > 0 && current.Document.Confidence > 0 {
+		return candidate.Document.Confidence > current.Document.Confidence
+	}
+	return filledFields(extractor, candidate) > filledFields(extractor, current)
+}
+
+func filledFields(extractor *data.DataExtractor, res ocr.OCRResult) int {
+	extracted := extractor.Extract(res.Document, res.Source)
+
+	filled := 0
+	for _, field := range []string{extracted.Name, extracted.Email, extracted.Phone, extracted.Text} {
+		if field != "" {
+			filled++
+		}
+	}
+	if len(extracted.Tags) > 0 {
+		filled++
+	}
+	return filled
+}
+
+// writeLayout saves the layout of an input to dir/<key>.json.
+func writeLayout(dir, key string, page layout.Layout) error {
+	content, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(dir, filepath.FromSlash(key)+".json")
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, content, 0644)
+}
@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"ocr-tool/internal/data"
+	"ocr-tool/internal/ocr"
+	"testing"
+	"time"
+)
+
+func TestBetter(t *testing.T) {
+	extractor := data.NewDataExtractor()
+	result := func(text string, confidence float64) ocr.OCRResult {
+		return ocr.OCRResult{Document: ocr.Document{Text: text, Confidence: confidence}}
+	}
+
+	testCases := []struct {
+		name      string
+		candidate ocr.OCRResult
+		current   ocr.OCRResult
+		expected  bool
+	}{
+		{
+			name:      "higher confidence wins",
+			candidate: result("a", 91),
+			current:   result("contact me at a@b.com +12345678901", 74),
+			expected:  true,
+		},
+		{
+			name:      "equal confidence keeps the earlier variant",
+			candidate: result("b", 80),
+			current:   result("a", 80),
+			expected:  false,
+		},
+		{
+			name:      "more extracted fields win without confidence",
+			candidate: result("mail a@b.com", 0),
+			current:   result("mail a@b", 0),
+			expected:  true,
+		},
+		{
+			name:      "fields decide when only one side reports confidence",
+			candidate: result("", 0),
+			current:   result("a", 60),
+			expected:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			actual := better(extractor, tc.candidate, tc.current)
+
+			// Assert
+			if actual != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// blockingEngine never finishes on its own, like a hung model call.
+type blockingEngine struct{}
+
+func (blockingEngine) ProcessImage(ctx context.Context, _ io.Reader) (ocr.Document, error) {
+	<-ctx.Done()
+	return ocr.Document{}, ctx.Err()
+}
+
+func (blockingEngine) Close() error { return nil }
+
+func TestProcessImage_Timeout(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name    string
+		ctx     context.Context
+		timeout bool
+	}{
+		{name: "timeout is reported as ErrTimeout", ctx: context.Background(), timeout: true},
+		{name: "cancellation is not a timeout", ctx: cancelled},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			_, err := processImage(tc.ctx, blockingEngine{}, nil, 10*time.Millisecond)
+
+			// Assert
+			if errors.Is(err, ErrTimeout) != tc.timeout {
+				t.Errorf("expected timeout=%v, got %v", tc.timeout, err)
+			}
+			if !tc.timeout && !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+		})
+	}
+}
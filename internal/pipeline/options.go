@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"fmt"
+	"ocr-tool/internal/image"
+	"ocr-tool/internal/ocr"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Options tunes the pipeline. Zero values of the concurrency settings are
+// replaced by the defaults for the engine, see DefaultOptions.
+type Options struct {
+	OCRWorkers     int // number of [performOcr] workers
+	EnhanceWorkers int // number of [enhanceImage] workers
+	MaxInFlight    int // enhanced images allowed to wait for or be in OCR
+	BufferSize     int // size of the buffered error and result channels
+
+	OCRTimeout time.Duration // longest engine call per image, 0 for none
+
+	ManifestPath string // JSON-lines journal of every input's outcome, empty to disable
+	Resume       bool   // skip inputs the manifest records as done with unchanged content
+
+	Recursive bool     // descend into subdirectories of the input directory
+	MaxDepth  int      // deepest subdirectory level walked when Recursive, 0 for unlimited
+	Include   []string // glob patterns of files to process, DefaultInclude when empty
+	Exclude   []string // glob patterns of files to ignore, applied after Include
+
+	SkipHidden bool // ignore files and directories whose name starts with a dot
+
+	Preprocess image.Chain // enhancement steps, image.DefaultChain when nil, none when empty
+	Variants   []Variant   // alternative chains, each image is OCRed once per variant; replaces Preprocess
+	DebugDir   string      // directory receiving the output of every step per input, empty to disable
+	LayoutDir  string      // directory receiving the engine layout JSON per input, empty to disable
+
+	Quality image.QualityThresholds // inputs failing them are reported without being OCRed, zero disables
+
+	Engine ocr.EngineOptions // settings of the OCR engines
+}
+
+// Variant is a named preprocessing chain. When several are configured the
+// result with the highest engine confidence, or failing that the most
+// extracted fields, is kept.
+type Variant struct {
+	Name       string      `json:"name"`
+	Preprocess image.Chain `json:"preprocess"`
+}
+
+// DefaultOptions derives worker counts from the number of CPUs. Tesseract is
+// CPU bound and scales with cores, whereas Ollama serialises requests on the
+// model server so extra workers only queue up there.
+func DefaultOptions(engineType string) Options {
+	cpus := runtime.NumCPU()
+
+	opts := Options{
+		OCRWorkers:     cpus,
+		EnhanceWorkers: max(1, cpus/2),
+		BufferSize:     10,
+	}
+	if engineType == "ollama" {
+		opts.OCRWorkers = 2
+		opts.EnhanceWorkers = 2
+	}
+	opts.MaxInFlight = opts.OCRWorkers * 2
+	return opts
+}
+
+func (o Options) withDefaults(engineType string) Options {
+	defaults := DefaultOptions(engineType)
+	if o.OCRWorkers <= 0 {
+		o.OCRWorkers = defaults.OCRWorkers
+	}
+	if o.EnhanceWorkers <= 0 {
+		o.EnhanceWorkers = defaults.EnhanceWorkers
+	}
+	if o.MaxInFlight <= 0 {
+		o.MaxInFlight = o.OCRWorkers * 2
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = defaults.BufferSize
+	}
+	if o.Preprocess == nil {
+		o.Preprocess = image.DefaultChain()
+	}
+	// Recognitions abandoned on timeout keep running, Tesseract must not
+	// start new ones beyond the workers
+	if o.Engine.Gosseract.Clients <= 0 {
+		o.Engine.Gosseract.Clients = o.OCRWorkers
+	}
+	return o
+}
+
+func (o Options) String() string {
+	preprocess := o.Preprocess.String()
+	if len(o.Variants) > 0 {
+		names := make([]string, len(o.Variants))
+		for i, variant := range o.Variants {
+			names[i] = fmt.Sprintf("%s=%s", variant.Name, variant.Preprocess)
+		}
+		preprocess = "[" + strings.Join(names, " ") + "]"
+	}
+	return fmt.Sprintf("ocrWorkers=%d, enhanceWorkers=%d, maxInFlight=%d, bufferSize=%d, preprocess=%s",
+		o.OCRWorkers, o.EnhanceWorkers, o.MaxInFlight, o.BufferSize, preprocess)
+}
+
+// ValidateVariants checks that every variant has a distinct name, used for
+// the Variant column and the debug image directories.
+func ValidateVariants(variants []Variant) error {
+	seen := make(map[string]bool, len(variants))
+	for _, variant := range variants {
+		if variant.Name == "" || strings.ContainsAny(variant.Name, `/\`) {
+			return fmt.Errorf("invalid variant name %q", variant.Name)
+		}
+		if seen[variant.Name] {
+			return fmt.Errorf("duplicate variant %q", variant.Name)
+		}
+		seen[variant.Name] = true
+	}
+	return nil
+}
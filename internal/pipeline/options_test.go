@@ -0,0 +1,47 @@
+package pipeline
+
+import "testing"
+
+func TestOptions_WithDefaults(t *testing.T) {
+	testCases := []struct {
+		name       string
+		engineType string
+		input      Options
+		check      func(Options) bool
+	}{
+		{
+			name:       "explicit values are kept",
+			engineType: "gosseract",
+			input:      Options{OCRWorkers: 7, EnhanceWorkers: 3, MaxInFlight: 9, BufferSize: 4},
+			check: func(o Options) bool {
+				return o.OCRWorkers == 7 && o.EnhanceWorkers == 3 && o.MaxInFlight == 9 && o.BufferSize == 4
+			},
+		},
+		{
+			name:       "max in-flight and Tesseract clients follow OCR workers",
+			engineType: "gosseract",
+			input:      Options{OCRWorkers: 5},
+			check: func(o Options) bool {
+				return o.MaxInFlight == 10 && o.EnhanceWorkers >= 1 && o.Engine.Gosseract.Clients == 5
+			},
+		},
+		{
+			name:       "ollama defaults to two OCR workers",
+			engineType: "ollama",
+			input:      Options{},
+			check:      func(o Options) bool { return o.OCRWorkers == 2 && o.MaxInFlight == 4 },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			actual := tc.input.withDefaults(tc.engineType)
+
+			// Assert
+			if !tc.check(actual) {
+				t.Errorf("unexpected options: %s", actual)
+			}
+		})
+	}
+}
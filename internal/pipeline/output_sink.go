@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"ocr-tool/internal/data"
+	"ocr-tool/internal/logger"
+	"ocr-tool/internal/writer"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// outputSink is a sink created by NewSink. When resuming, the records of
+// inputs whose previous write was interrupted are only written if the output
+// does not already hold them, so the crash does not leave duplicate rows.
+type outputSink struct {
+	writer.Sink[data.ExtractedData]
+	format  writer.Format
+	path    string
+	inDoubt func(key string) bool // nil when not resuming
+	written map[string]bool       // keys found in the output, loaded on first need
+}
+
+func (s *outputSink) Write(records []data.ExtractedData) error {
+	kept := make([]data.ExtractedData, 0, len(records))
+	for _, record := range records {
+		key := inputKey(record.Filename, record.Page)
+		if s.inDoubt != nil && s.inDoubt(key) && s.holds(key) {
+			logger.DebugLog("[outputSink]: %s already holds %s, not writing it again", s.path, key)
+			continue
+		}
+		kept = append(kept, record)
+	}
+	if len(kept) == 0 && len(records) > 0 {
+		return nil
+	}
+	return s.Sink.Write(kept)
+}
+
+// holds reports whether the output file has a record for key. An output
+// that cannot be read is written to anyway.
+func (s *outputSink) holds(key string) bool {
+	if s.written == nil {
+		written, err := readKeys(s.format, s.path)
+		if err != nil {
+			logger.DebugLog("[outputSink]: reading %s: %v", s.path, err)
+		}
+		s.written = written
+	}
+	return s.written[key]
+}
+
+// readKeys returns the input keys of the records in the output file.
+func readKeys(format writer.Format, path string) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return keys, err
+	}
+	defer file.Close()
+
+	switch format {
+	case writer.FormatJSON:
+		var records []data.ExtractedData
+		if err := json.NewDecoder(file).Decode(&records); err != nil && err != io.EOF {
+			return keys, err
+		}
+		for _, record := range records {
+			keys[inputKey(record.Filename, record.Page)] = true
+		}
+	case writer.FormatNDJSON:
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(nil, 16<<20)
+		for scanner.Scan() {
+			var record data.ExtractedData
+			if json.Unmarshal(scanner.Bytes(), &record) == nil {
+				keys[inputKey(record.Filename, record.Page)] = true
+			}
+		}
+		return keys, scanner.Err()
+	default:
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return keys, err
+		}
+		name, page := slices.Index(header, "Filename"), slices.Index(header, "Page")
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return keys, err
+			}
+			if name < 0 || name >= len(row) {
+				continue
+			}
+			n := 0
+			if page >= 0 && page < len(row) {
+				n, _ = strconv.Atoi(row[page])
+			}
+			keys[inputKey(row[name], n)] = true
+		}
+	}
+	return keys, nil
+}
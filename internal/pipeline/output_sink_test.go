@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	goimage "image"
+	"image/png"
+	"io"
+	"ocr-tool/internal/data"
+	"ocr-tool/internal/image"
+	"ocr-tool/internal/ocr"
+	"ocr-tool/internal/writer"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutputSink_SkipsRecordsWrittenBeforeCrash(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format writer.Format
+	}{
+		{name: "csv", format: writer.FormatCSV},
+		{name: "json", format: writer.FormatJSON},
+		{name: "ndjson", format: writer.FormatNDJSON},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange: a.png was written but not journaled as done
+			path := filepath.Join(t.TempDir(), "out."+tc.name)
+			first := NewSink(tc.format, path)
+			if err := first.Write([]data.ExtractedData{{Filename: "a.png", Page: 2}}); err != nil {
+				t.Fatalf("first write: %v", err)
+			}
+			if err := first.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+			inDoubt := map[string]bool{inputKey("a.png", 2): true, inputKey("b.png", 0): true}
+
+			// Act
+			resumed := NewSink(tc.format, path)
+			resumed.(*outputSink).inDoubt = func(key string) bool { return inDoubt[key] }
+			for _, record := range []data.ExtractedData{{Filename: "a.png", Page: 2}, {Filename: "b.png"}} {
+				if err := resumed.Write([]data.ExtractedData{record}); err != nil {
+					t.Fatalf("resumed write: %v", err)
+				}
+			}
+			if err := resumed.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+
+			// Assert
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading output: %v", err)
+			}
+			if n := strings.Count(string(content), "a.png"); n != 1 {
+				t.Errorf("expected a.png once, got %d times in:\n%s", n, content)
+			}
+			if n := strings.Count(string(content), "b.png"); n != 1 {
+				t.Errorf("expected b.png once, got %d times in:\n%s", n, content)
+			}
+		})
+	}
+}
+
+// textEngine recognizes the same text in every image.
+type textEngine struct{}
+
+func (textEngine) ProcessImage(context.Context, io.Reader) (ocr.Document, error) {
+	return ocr.Document{Text: "Jane Doe jane@example.com"}, nil
+}
+
+func (textEngine) Close() error { return nil }
+
+// failingSink fails every write, like a full disk.
+type failingSink struct{}
+
+func (failingSink) Write([]data.ExtractedData) error { return errors.New("disk full") }
+func (failingSink) Flush() error                     { return nil }
+func (failingSink) Close() error                     { return nil }
+
+func TestRun_ResumeAfterFailingSinkDoesNotDuplicate(t *testing.T) {
+	// Arrange: the CSV output gets the record, the other output fails
+	root, out := t.TempDir(), t.TempDir()
+	var pngBytes bytes.Buffer
+	if err := png.Encode(&pngBytes, goimage.NewGray(goimage.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encoding PNG failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.png"), pngBytes.Bytes(), 0644); err != nil {
+		t.Fatalf("creating file failed: %v", err)
+	}
+	defer func(create func(string, ocr.EngineOptions) (ocr.OCREngine, error)) { newEngine = create }(newEngine)
+	newEngine = func(string, ocr.EngineOptions) (ocr.OCREngine, error) { return textEngine{}, nil }
+
+	csvPath, ndjsonPath := filepath.Join(out, "out.csv"), filepath.Join(out, "out.ndjson")
+	opts := Options{Preprocess: image.Chain{}, ManifestPath: filepath.Join(out, "manifest.jsonl")}
+	_, failures := Run(context.Background(), "gosseract", root, opts, NewSink(writer.FormatCSV, csvPath), failingSink{})
+	if failures["a.png"] == nil {
+		t.Fatalf("expected a.png to fail, got %v", failures)
+	}
+
+	// Act
+	opts.Resume = true
+	writes, failures := Run(context.Background(), "gosseract", root, opts,
+		NewSink(writer.FormatCSV, csvPath), NewSink(writer.FormatNDJSON, ndjsonPath))
+
+	// Assert
+	if _, ok := writes["a.png"]; !ok {
+		t.Fatalf("expected a.png to be retried, got failures %v", failures)
+	}
+	for _, path := range []string{csvPath, ndjsonPath} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading output failed: %v", err)
+		}
+		if n := strings.Count(string(content), "a.png"); n != 1 {
+			t.Errorf("expected a.png once in %s, got %d times in:\n%s", filepath.Base(path), n, content)
+		}
+	}
+}
@@ -2,9 +2,11 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"ocr-tool/internal/data"
 	"ocr-tool/internal/image"
 	"ocr-tool/internal/logger"
+	"ocr-tool/internal/manifest"
 	"ocr-tool/internal/ocr"
 	"ocr-tool/internal/writer"
 	"sync"
@@ -20,35 +22,71 @@ type writeResult[T any] struct {
 	mu       sync.Mutex
 	writes   map[string]T
 	failures map[string]error
+	journal  *manifest.Manifest // nil when the run is not journaled
 }
 
 type Clients struct {
 	engine ocr.OCREngine
-	image  image.ImageProcessor
+	images []imageVariant
 	data   data.DataExtractor
-	writer *writer.CSVWriter[data.ExtractedData]
+	writer writer.Sink[data.ExtractedData]
+}
+
+// imageVariant is the processor of one preprocessing variant. The single
+// variant of a run without Options.Variants has no name.
+type imageVariant struct {
+	name      string
+	processor *image.ImageProcessor
 }
 
 type contextKey string
 
 const clientsKey contextKey = "all_my_clients"
 
-const outputFileKey contextKey = "output_file"
-
-const (
-	enhancedImageThreshold = 5
-	ocrProcessorThreshold  = 2 // hard limit on OCR workers for now
-	channelBufferSize      = 10
-)
-
-func Run(engineType string, directory string, outputFile string) (writes map[string]data.ExtractedData, failures map[string]error) {
-	ctx, cancel := context.WithCancel(context.Background())
+// newEngine creates the OCR engine of a run, replaced by tests.
+var newEngine = ocr.NewEngine
+
+// ErrSkipped marks inputs that were never processed because the run was
+// cancelled. Failures wrapping it can be retried as-is.
+var ErrSkipped = errors.New("skipped")
+
+// ErrTimeout marks inputs whose OCR took longer than Options.OCRTimeout.
+var ErrTimeout = errors.New("OCR timed out")
+
+// Run processes every image in directory and fans the extracted records out
+// to all sinks. The pipeline takes ownership of the sinks and closes them
+// once the last record has been written.
+//
+// Cancelling ctx stops the discovery of new work and abandons the images in
+// OCR: results already recognized are written and everything else is
+// reported as ErrSkipped.
+// Images are processed in memory, nothing is written next to the inputs.
+func Run(ctx context.Context, engineType string, directory string, opts Options, sinks ...writer.Sink[data.ExtractedData]) (writes map[string]data.ExtractedData, failures map[string]error) {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	logger.DebugLog("Pipeline started with engineType=%s, directory=%s, output=%s", engineType, directory, outputFile)
+	opts = opts.withDefaults(engineType)
+	logger.DebugLog("Pipeline started with engineType=%s, directory=%s, sinks=%d, %s", engineType, directory, len(sinks), opts)
+
+	var journal *manifest.Manifest
+	if opts.ManifestPath != "" {
+		var err error
+		if journal, err = manifest.Open(opts.ManifestPath, opts.Resume); err != nil {
+			logger.DebugLog("Failed to open manifest: %v", err)
+			writer.NewMultiSink(sinks...).Close()
+			return nil, map[string]error{"manifest": err}
+		}
+		defer journal.Close()
+		for _, sink := range sinks {
+			if s, ok := sink.(*outputSink); ok {
+				s.inDoubt = journal.InDoubt
+			}
+		}
+	}
 
-	ocrEngine, err := ocr.NewEngine(engineType)
+	ocrEngine, err := newEngine(engineType, opts.Engine)
 	if err != nil {
 		logger.DebugLog("Failed to create OCR engine: %v", err)
+		writer.NewMultiSink(sinks...).Close()
 		return nil, map[string]error{"engine": err}
 	}
 	defer func() {
@@ -58,49 +96,68 @@ func Run(engineType string, directory string, outputFile string) (writes map[str
 
 	clients := &Clients{
 		engine: ocrEngine,
-		image:  *image.NewImageProcessor(),
+		images: newImageVariants(opts),
 		data:   *data.NewDataExtractor(),
-		writer: writer.NewCSVWriter(data.MapCSVRecord, data.GetCSVHeader),
+		writer: writer.NewMultiSink(sinks...),
 	}
 
 	// Embed clients in context
 	ctx = context.WithValue(ctx, clientsKey, clients)
-	ctx = context.WithValue(ctx, outputFileKey, outputFile)
 
-	errChan := make(chan error, channelBufferSize) // Buffered channel to collect errors
-	files := make(chan string)                     // Unbuffered channel for file paths
+	errChan := make(chan error, opts.BufferSize) // Buffered channel to collect errors
+	files := make(chan inputFile)                // Unbuffered channel for discovered files
 	enhancedChan := make(chan enhancedChanItem)
-	throttledChan := make(chan struct{}, enhancedImageThreshold)            // Limiter channel limiting number of enhanced images that have not yet completed OCR
-	ocrChan := make(chan ocr.OCRResult)                                     // Buffered channel for OCR results from enhanced images
-	extractChan := make(chan result[data.ExtractedData], channelBufferSize) // Buffered channel for extraction tasks (OCR results to extracted data)
+	throttledChan := make(chan struct{}, opts.MaxInFlight)                // Limiter channel limiting number of enhanced images that have not yet completed OCR
+	ocrChan := make(chan ocr.OCRResult)                                   // Unbuffered channel for OCR results from enhanced images
+	extractChan := make(chan result[data.ExtractedData], opts.BufferSize) // Buffered channel for extraction tasks (OCR results to extracted data)
 	results := &writeResult[data.ExtractedData]{
 		writes:   make(map[string]data.ExtractedData), // Map to store extracted data
 		failures: make(map[string]error),              // Map to store failures
+		journal:  journal,
 	}
 
+	// Collect errors while the pipeline runs so that stages never block on a full errChan
+	errDone := make(chan struct{})
+	go func() {
+		defer close(errDone)
+		for err := range errChan {
+			if err != nil {
+				logger.DebugLog("Error received in errChan: %v", err)
+				results.addFailure("pipeline_error", err)
+			}
+		}
+	}()
+
 	go func() {
 		defer close(files)
 		logger.DebugLog("Starting [walkFiles] goroutine")
-		walkFiles(ctx, directory, files, errChan)
+		walkFiles(ctx, directory, opts, files, results, errChan)
 		defer logger.DebugLog("[walkFiles] goroutine finished")
 	}()
 
+	var enhanceWg sync.WaitGroup
+	for i := 0; i < opts.EnhanceWorkers; i++ {
+		enhanceWg.Add(1)
+		go func(worker int) {
+			defer enhanceWg.Done()
+			logger.DebugLog("Starting [enhanceImage] worker #%d (semaphore-limited)", worker+1)
+			enhanceImage(ctx, files, enhancedChan, throttledChan, opts, results, errChan)
+			defer logger.DebugLog("[enhanceImage] worker #%d finished", worker+1)
+		}(i)
+	}
 	go func() {
-		defer close(enhancedChan)
-		logger.DebugLog("Starting [enhanceImage] goroutine (semaphore-limited)")
-		enhanceImage(ctx, files, enhancedChan, throttledChan, errChan)
-		defer logger.DebugLog("[enhanceImage] goroutine finished")
+		enhanceWg.Wait()
+		logger.DebugLog("All [enhanceImage] workers finished, closing enhancedChan")
+		close(enhancedChan)
 	}()
 
-	processCount := ocrProcessorThreshold
 	var wg sync.WaitGroup
-
-	for i := 0; i < processCount; i++ {
+	for i := 0; i < opts.OCRWorkers; i++ {
 		wg.Add(1)
 		go func(worker int) {
 			defer wg.Done()
 			logger.DebugLog("Starting [performOcr] worker #%d", worker+1)
-			performOcr(ctx, enhancedChan, ocrChan, errChan)
+			performOcr(ctx, enhancedChan, ocrChan, opts, results, errChan)
 			defer logger.DebugLog("[performOcr] worker #%d finished", worker+1)
 		}(i)
 	}
@@ -110,73 +167,59 @@ func Run(engineType string, directory string, outputFile string) (writes map[str
 		close(ocrChan)
 	}()
 
-	// fan-out - forward ocr results to extraction + cleanup
-	extractInput := make(chan ocr.OCRResult)
-	cleanupInput := make(chan ocr.OCRResult, channelBufferSize) // Buffered channel for cleanup files created during enhancement
-
-	go func() {
-		logger.DebugLog("Starting [forwardChan] for ocrChan -> extractInput, cleanupInput")
-		forwardChan(ctx, ocrChan, extractInput, cleanupInput)
-		defer logger.DebugLog("[forwardChan] for ocrChan finished")
-	}()
-
 	go func() {
 		defer close(extractChan)
 		logger.DebugLog("Starting [extractData] goroutine")
-		extractData(ctx, extractInput, extractChan, errChan)
+		extractData(ctx, ocrChan, extractChan, errChan)
 		defer logger.DebugLog("[extractData] goroutine finished")
 	}()
 
+	writeDone := make(chan struct{})
 	go func() {
-		logger.DebugLog("Starting [cleanupImage] goroutine")
-		cleanupImage(ctx, cleanupInput, errChan)
-		defer logger.DebugLog("[cleanupImage] goroutine finished")
-	}()
-
-	var writeWg sync.WaitGroup
-	writeWg.Add(1)
-	go func() {
-		defer writeWg.Done()
+		defer close(writeDone)
 		logger.DebugLog("Starting [writeOutput] goroutine")
 		writeOutput(ctx, extractChan, results, errChan)
 		defer logger.DebugLog("[writeOutput] goroutine finished")
 	}()
 
-	writeWg.Wait()
-	logger.DebugLog("All [writeOutput] finished, closing errChan")
+	<-writeDone
+	logger.DebugLog("[writeOutput] finished, closing errChan")
 	close(errChan)
-	for err := range errChan {
-		if err != nil {
-			logger.DebugLog("Error received in errChan: %v", err)
-			results.addFailure("pipeline_error", err)
-		}
-	}
+	<-errDone
 
 	logger.DebugLog("Pipeline finished")
 	return results.writes, results.failures
 }
 
-func forwardChan[T any](ctx context.Context, in <-chan T, outs ...chan<- T) {
-	defer func() {
-		for _, out := range outs {
-			close(out)
-		}
-	}()
+func newImageVariants(opts Options) []imageVariant {
+	variants := []Variant{{Preprocess: opts.Preprocess}}
+	if len(opts.Variants) > 0 {
+		variants = opts.Variants
+	}
 
-	for res := range in {
-		if ctx.Err() != nil {
-			logger.DebugLog("forwardChan: context cancelled")
-			return
+	images := make([]imageVariant, len(variants))
+	for i, variant := range variants {
+		processor := image.NewImageProcessor(variant.Preprocess)
+		if opts.DebugDir != "" {
+			processor.TraceSteps()
 		}
+		images[i] = imageVariant{name: variant.Name, processor: processor}
+	}
+	return images
+}
 
-		logger.DebugLog("forwardChan: forwarding result to %d outputs", len(outs))
-		for _, out := range outs {
-			select {
-			case out <- res:
-			case <-ctx.Done():
-				logger.DebugLog("forwardChan: context done while forwarding")
-				return
-			}
-		}
+// NewSink creates a sink writing extracted records to outputFile in format.
+// When Run resumes, it skips the records the file already holds for inputs
+// whose previous write was interrupted.
+func NewSink(format writer.Format, outputFile string) writer.Sink[data.ExtractedData] {
+	sink := &outputSink{format: format, path: outputFile}
+	switch format {
+	case writer.FormatJSON:
+		sink.Sink = writer.NewJSONSink[data.ExtractedData](outputFile)
+	case writer.FormatNDJSON:
+		sink.Sink = writer.NewNDJSONSink[data.ExtractedData](outputFile)
+	default:
+		sink.Sink = writer.NewCSVSink(outputFile, data.MapCSVRecord, data.GetCSVHeader)
 	}
+	return sink
 }
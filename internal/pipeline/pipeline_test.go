@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	goimage "image"
+	"image/png"
+	"io"
+	"ocr-tool/internal/image"
+	"ocr-tool/internal/manifest"
+	"ocr-tool/internal/ocr"
+	"ocr-tool/internal/writer"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// cancellingEngine recognizes one image and cancels the run, like a Ctrl-C
+// arriving during the first OCR call.
+type cancellingEngine struct {
+	cancel context.CancelFunc
+	calls  *atomic.Int32
+}
+
+func (e cancellingEngine) ProcessImage(_ context.Context, _ io.Reader) (ocr.Document, error) {
+	e.calls.Add(1)
+	e.cancel()
+	return ocr.Document{Text: "Jane Doe jane@example.com"}, nil
+}
+
+func (cancellingEngine) Close() error { return nil }
+
+func TestRun_CancelSkipsRemainingInputs(t *testing.T) {
+	// Arrange
+	const inputs = 20
+	root, out := t.TempDir(), t.TempDir()
+	var pngBytes bytes.Buffer
+	if err := png.Encode(&pngBytes, goimage.NewGray(goimage.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encoding PNG failed: %v", err)
+	}
+	for i := range inputs {
+		name := filepath.Join(root, string(rune('a'+i))+".png")
+		if err := os.WriteFile(name, pngBytes.Bytes(), 0644); err != nil {
+			t.Fatalf("creating file failed: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls atomic.Int32
+	defer func(create func(string, ocr.EngineOptions) (ocr.OCREngine, error)) { newEngine = create }(newEngine)
+	newEngine = func(string, ocr.EngineOptions) (ocr.OCREngine, error) {
+		return cancellingEngine{cancel: cancel, calls: &calls}, nil
+	}
+
+	manifestPath := filepath.Join(out, "manifest.jsonl")
+	outputPath := filepath.Join(out, "out.ndjson")
+	opts := Options{
+		OCRWorkers:     1,
+		EnhanceWorkers: 1,
+		MaxInFlight:    1,
+		Preprocess:     image.Chain{},
+		ManifestPath:   manifestPath,
+	}
+
+	// Act
+	writes, failures := Run(ctx, "gosseract", root, opts, NewSink(writer.FormatNDJSON, outputPath))
+
+	// Assert
+	if calls.Load() != 1 {
+		t.Errorf("expected a single OCR call, got %d", calls.Load())
+	}
+	if len(writes) > 1 {
+		t.Errorf("expected at most the recognized image to be written, got %d", len(writes))
+	}
+	content, err := os.ReadFile(outputPath)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("reading output failed: %v", err)
+	}
+	if rows := strings.Count(string(content), "\n"); rows != len(writes) {
+		t.Errorf("expected %d output rows, got %d", len(writes), rows)
+	}
+	if len(writes)+len(failures) != inputs {
+		t.Errorf("expected %d inputs reported, got %d writes and %d failures: %v", inputs, len(writes), len(failures), failures)
+	}
+
+	journal, err := os.Open(manifestPath)
+	if err != nil {
+		t.Fatalf("opening manifest failed: %v", err)
+	}
+	defer journal.Close()
+	last := make(map[string]manifest.Entry)
+	scanner := bufio.NewScanner(journal)
+	for scanner.Scan() {
+		var entry manifest.Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("decoding manifest entry failed: %v", err)
+		}
+		last[entry.Path] = entry
+	}
+	unread := 0
+	for i := range inputs {
+		name := string(rune('a'+i)) + ".png"
+		entry, ok := last[name]
+		if _, written := writes[name]; written {
+			if entry.Status != manifest.StatusDone {
+				t.Errorf("expected %s journaled done, got %+v", name, entry)
+			}
+			continue
+		}
+		if !ok || entry.Status != manifest.StatusSkipped {
+			t.Errorf("expected %s journaled skipped, got %+v", name, entry)
+		}
+		if entry.Hash == "" {
+			unread++
+		}
+	}
+	// Only the few inputs already handed to the workers were read
+	if unread < inputs-5 {
+		t.Errorf("expected the inputs found after cancellation to be skipped unread, got %d of %d", unread, inputs)
+	}
+}
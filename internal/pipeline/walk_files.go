@@ -1,48 +1,339 @@
 package pipeline
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"io/fs"
+	"ocr-tool/internal/data"
+	"ocr-tool/internal/image"
 	"ocr-tool/internal/logger"
+	"ocr-tool/internal/manifest"
+	"ocr-tool/internal/pdf"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
 
-func walkFiles(ctx context.Context, directory string, results chan<- string, errChan chan<- error) {
-	files, err := os.ReadDir(directory)
+// DefaultInclude is used when no --include pattern is given. Every file is
+// considered and the format is decided from its content.
+var DefaultInclude = []string{"*"}
+
+// inputFile is a unit of work discovered by walkFiles. Name is the slash
+// separated path relative to the walked root and becomes
+// ExtractedData.Filename.
+type inputFile struct {
+	Path   string
+	Name   string
+	Format image.Format
+	Page   int           // 1-based page of a multi-page file, 0 for single images
+	Data   []byte        // content of an archive entry, Path is then the archive
+	doc    *pdf.Document // parsed PDF shared by all its pages
+}
+
+// inputReader is implemented by both *os.File and *bytes.Reader.
+type inputReader interface {
+	io.ReadSeeker
+	io.ReaderAt
+	io.Closer
+}
+
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (memoryReader) Close() error { return nil }
+
+// open returns the content of the file, read from memory for archive
+// entries.
+func (f inputFile) open() (inputReader, error) {
+	if f.Data != nil {
+		return memoryReader{bytes.NewReader(f.Data)}, nil
+	}
+	return os.Open(f.Path)
+}
+
+// Key identifies the unit of work in results, failures and the manifest.
+func (f inputFile) Key() string {
+	return inputKey(f.Name, f.Page)
+}
+
+func inputKey(name string, page int) string {
+	if page == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s#page=%d", name, page)
+}
+
+func walkFiles(ctx context.Context, directory string, opts Options, results chan<- inputFile, outcome *writeResult[data.ExtractedData], errChan chan<- error) {
+	// After cancellation the remaining files are still listed so that they
+	// show up as skipped in the run summary, but they are no longer read
+	err := filepath.WalkDir(directory, func(fullPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if fullPath == directory {
+				return err
+			}
+			logger.DebugLog("[walkFiles]: failed to read %s: %v", fullPath, err)
+			errChan <- fmt.Errorf("[walkFiles]: reading %s: %w", fullPath, err)
+			return nil
+		}
+
+		rel, err := filepath.Rel(directory, fullPath)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			if fullPath == directory {
+				return nil
+			}
+			if (opts.SkipHidden && isHidden(entry.Name())) || !opts.Recursive || (opts.MaxDepth > 0 && pathDepth(name) > opts.MaxDepth) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if opts.SkipHidden && isHidden(entry.Name()) {
+			return nil
+		}
+		if ctx.Err() != nil {
+			if matchesPatterns(name, opts.Include, opts.Exclude) {
+				skipInput(outcome, name, ctx.Err())
+			}
+			return nil
+		}
+
+		// Archives are virtual directories, include patterns apply to
+		// their entries only
+		if format, ok := detectArchive(fullPath); ok {
+			if !matchesAny(name, opts.Exclude) {
+				walkArchiveEntries(ctx, fullPath, name, format, opts, results, outcome)
+			}
+			return nil
+		}
+
+		if !matchesPatterns(name, opts.Include, opts.Exclude) {
+			return nil
+		}
+		sendInput(ctx, inputFile{Path: fullPath, Name: name}, results, outcome)
+		return nil
+	})
 	if err != nil {
-		logger.DebugLog("[walkFiles]: failed to read directory %s: %v", directory, err)
+		logger.DebugLog("[walkFiles]: failed to walk directory %s: %v", directory, err)
 		errChan <- fmt.Errorf("[walkFiles]: reading directory %s: %w", directory, err)
-		return
 	}
+}
 
-	for _, file := range files {
+// walkArchiveEntries sends the entries of the archive at fullPath as if it
+// were a directory named name. Its root entries are always read, deeper ones
+// follow --recursive and --max-depth.
+func walkArchiveEntries(ctx context.Context, fullPath, name string, format archiveFormat, opts Options, results chan<- inputFile, outcome *writeResult[data.ExtractedData]) {
+	logger.DebugLog("[walkFiles]: reading %s archive %s", format, name)
+	accept := func(inner string) bool {
+		entryName := name + archiveSeparator + inner
+		depth := pathDepth(name) + pathDepth(inner) - 1
+		if (opts.SkipHidden && isHiddenPath(inner)) || !matchesPatterns(entryName, opts.Include, opts.Exclude) {
+			return false
+		}
+		if pathDepth(inner) > 1 && (!opts.Recursive || (opts.MaxDepth > 0 && depth > opts.MaxDepth)) {
+			return false
+		}
 		if ctx.Err() != nil {
-			logger.DebugLog("[walkFiles]: context cancelled")
+			skipInput(outcome, entryName, ctx.Err())
+			return false
+		}
+		return true
+	}
+	err := walkArchive(fullPath, format, accept, func(inner string, content []byte, err error) {
+		entryName := name + archiveSeparator + inner
+		if err != nil {
+			logger.DebugLog("[walkFiles]: failed to read %s: %v", entryName, err)
+			outcome.addFailure(entryName, fmt.Errorf("reading %s: %w", entryName, err))
 			return
 		}
+		sendInput(ctx, inputFile{Path: fullPath, Name: entryName, Data: content}, results, outcome)
+	})
+	if err != nil {
+		logger.DebugLog("[walkFiles]: failed to read archive %s: %v", name, err)
+		outcome.addFailure(name, fmt.Errorf("reading archive %s: %w", name, err))
+	}
+}
+
+// sendInput detects the format of the file and sends one unit of work per
+// page, recording files that are already completed, rejected or skipped.
+func sendInput(ctx context.Context, file inputFile, results chan<- inputFile, outcome *writeResult[data.ExtractedData]) {
+	if ctx.Err() != nil {
+		skipInput(outcome, file.Name, ctx.Err())
+		return
+	}
+
+	// Decide from the content, so misnamed images are kept and corrupt
+	// or non-image files are reported rather than silently dropped. They
+	// are journaled without a hash, being retried on resume anyway
+	units, err := splitPages(file)
+	if err != nil {
+		logger.DebugLog("[walkFiles]: rejecting %s: %v", file.Name, err)
+		outcome.journal.Track(file.Key(), "")
+		outcome.addFailure(file.Key(), fmt.Errorf("rejecting %s: %w", file.Name, err))
+		return
+	}
+
+	hash, ok := hashFile(outcome, file)
+	if !ok {
+		return
+	}
 
-		fileName := file.Name()
-		if file.IsDir() || isProcessedFile(fileName) || !isImageFile(fileName) {
+	for _, unit := range units {
+		if !trackFile(outcome, unit, hash) {
 			continue
 		}
-		fullPath := filepath.Join(directory, fileName)
-		logger.DebugLog("[walkFiles]: sending file %s", fullPath)
+		if ctx.Err() != nil {
+			outcome.addSkipped(unit.Key(), ctx.Err())
+			continue
+		}
+
+		logger.DebugLog("[walkFiles]: sending file %s", unit.Key())
 		select {
-		case results <- fullPath:
+		case results <- unit:
 		case <-ctx.Done():
-			logger.DebugLog("[walkFiles]: context done while sending file %s", fullPath)
-			return
+			logger.DebugLog("[walkFiles]: context done while sending file %s", unit.Key())
+			outcome.addSkipped(unit.Key(), ctx.Err())
+		}
+	}
+}
+
+// splitPages detects the format of the file and returns one unit of work
+// per page for multi-page TIFFs and PDFs, or the file itself otherwise.
+func splitPages(file inputFile) ([]inputFile, error) {
+	r, err := file.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	format, err := image.DetectFormatReader(r)
+	if err != nil {
+		return nil, err
+	}
+	file.Format = format
+
+	var pages int
+	switch format {
+	case image.FormatTIFF:
+		if pages, err = image.TIFFPageCount(r); err != nil {
+			return nil, err
+		}
+		if pages <= 1 {
+			return []inputFile{file}, nil
+		}
+	case image.FormatPDF:
+		// PDF pages are always numbered, even for single-page documents
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
 		}
+		doc, err := pdf.Open(content)
+		if err != nil {
+			return nil, err
+		}
+		file.doc = doc
+		pages = doc.NumPages()
+	default:
+		return []inputFile{file}, nil
+	}
+
+	units := make([]inputFile, pages)
+	for i := range units {
+		units[i] = file
+		units[i].Page = i + 1
 	}
+	return units, nil
 }
 
-func isProcessedFile(filename string) bool {
-	return strings.Contains(filename, "_processed")
+// hashFile returns the content hash used by the run manifest. It reports
+// false when the file cannot be read, after recording the failure.
+func hashFile(outcome *writeResult[data.ExtractedData], file inputFile) (string, bool) {
+	if outcome.journal == nil {
+		return "", true
+	}
+
+	hash, err := hashInput(file)
+	if err != nil {
+		logger.DebugLog("[walkFiles]: failed to hash %s: %v", file.Name, err)
+		outcome.addFailure(file.Name, fmt.Errorf("hashing file %s: %w", file.Name, err))
+		return "", false
+	}
+	return hash, true
+}
+
+func hashInput(file inputFile) (string, error) {
+	r, err := file.open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	return manifest.Hash(r)
+}
+
+// skipInput reports an input found after cancellation as skipped. It is
+// journaled by name only since reading it to split its pages or hash it is
+// the work cancellation is meant to stop.
+func skipInput(outcome *writeResult[data.ExtractedData], name string, cause error) {
+	if outcome.journal.Written(name) {
+		return
+	}
+	outcome.journal.Track(name, "")
+	outcome.addSkipped(name, cause)
+}
+
+// trackFile registers the unit in the run manifest and reports whether it
+// still needs processing.
+func trackFile(outcome *writeResult[data.ExtractedData], file inputFile, hash string) bool {
+	if outcome.journal.Completed(file.Key(), hash) {
+		logger.DebugLog("[walkFiles]: %s already completed, skipping", file.Key())
+		return false
+	}
+	outcome.journal.Track(file.Key(), hash)
+	return true
+}
+
+func pathDepth(name string) int {
+	return strings.Count(name, "/") + 1
+}
+
+// matchesPatterns reports whether name is included and not excluded.
+// Patterns without a slash match the base name, others the whole relative
+// path. Matching is case-insensitive.
+func matchesPatterns(name string, include, exclude []string) bool {
+	if len(include) == 0 {
+		include = DefaultInclude
+	}
+	return matchesAny(name, include) && !matchesAny(name, exclude)
+}
+
+func matchesAny(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	base := path.Base(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(filepath.ToSlash(pattern))
+		target := base
+		if strings.Contains(pattern, "/") {
+			target = name
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
 }
 
-func isImageFile(filename string) bool {
-	ext := strings.ToLower(filename[strings.LastIndex(filename, ".")+1:])
-	return ext == "jpg" || ext == "jpeg" || ext == "png" || ext == "tiff" || ext == "bmp"
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
 }
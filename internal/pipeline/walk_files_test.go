@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	goimage "image"
+	"image/png"
+	"ocr-tool/internal/data"
+	"ocr-tool/internal/image"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestWalkFiles_RecursiveWithPatterns(t *testing.T) {
+	// Arrange
+	root := t.TempDir()
+	var pngBytes bytes.Buffer
+	if err := png.Encode(&pngBytes, goimage.NewGray(goimage.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encoding PNG failed: %v", err)
+	}
+	for _, name := range []string{
+		"top.png",
+		"notes.txt",
+		"top_processed.png",
+		"2024/01/batch1/scan.png",
+		"2024/01/batch1/scan.JPG",
+		"2024/02/batch1/scan.png",
+		"2024/02/drafts/skip.png",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating directory failed: %v", err)
+		}
+		content := pngBytes.Bytes()
+		if filepath.Ext(name) == ".txt" {
+			content = []byte("not an image")
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("creating file failed: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		opts     Options
+		expected []string
+		rejected []string
+	}{
+		{
+			name:     "top level only by default",
+			opts:     Options{},
+			expected: []string{"top.png", "top_processed.png"},
+			rejected: []string{"notes.txt"},
+		},
+		{
+			name:     "recursive with relative names",
+			opts:     Options{Recursive: true, Exclude: []string{"2024/*/drafts/*", "*.txt"}},
+			expected: []string{"2024/01/batch1/scan.JPG", "2024/01/batch1/scan.png", "2024/02/batch1/scan.png", "top.png", "top_processed.png"},
+		},
+		{
+			name:     "max depth stops descent",
+			opts:     Options{Recursive: true, MaxDepth: 2, Include: []string{"*.png"}},
+			expected: []string{"top.png", "top_processed.png"},
+		},
+		{
+			name:     "include narrows the candidates",
+			opts:     Options{Recursive: true, Include: []string{"*.jpg"}},
+			expected: []string{"2024/01/batch1/scan.JPG"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			files := make(chan inputFile)
+			errChan := make(chan error, 10)
+			outcome := &writeResult[data.ExtractedData]{
+				writes:   make(map[string]data.ExtractedData),
+				failures: make(map[string]error),
+			}
+
+			// Act
+			go func() {
+				defer close(files)
+				walkFiles(context.Background(), root, tc.opts, files, outcome, errChan)
+			}()
+			var actual []string
+			for file := range files {
+				actual = append(actual, file.Name)
+			}
+			sort.Strings(actual)
+
+			// Assert
+			if len(errChan) > 0 {
+				t.Fatalf("unexpected walk error: %v", <-errChan)
+			}
+			if !slices.Equal(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+			for _, name := range tc.rejected {
+				if err := outcome.failures[name]; !errors.Is(err, image.ErrUnsupportedFormat) {
+					t.Errorf("expected %s to be rejected as unsupported, got %v", name, err)
+				}
+			}
+		})
+	}
+}
@@ -2,9 +2,11 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"ocr-tool/internal/data"
 	"ocr-tool/internal/logger"
+	"ocr-tool/internal/manifest"
 )
 
 func writeOutput(ctx context.Context,
@@ -20,24 +22,21 @@ func writeOutput(ctx context.Context,
 	}
 	writer := proc.writer
 
-	output := ctx.Value(outputFileKey).(string)
-
+	// Keep draining after cancellation so every completed record is flushed
 	for res := range extractedChan {
-		if ctx.Err() != nil {
-			logger.DebugLog("[writeOutput]: context cancelled")
-			return
-		}
-
 		if res.err != nil {
 			logger.DebugLog("[writeOutput]: failure for %s: %v", res.path, res.err)
 			results.addFailure(res.path, res.err)
 			continue
 		}
 
+		// Journaled first so that a crash before the done entry leaves the
+		// input in doubt rather than looking never written, see NewSink
 		logger.DebugLog("[writeOutput]: writing data for %s", res.path)
-		if err := writer.WriteToFile([]data.ExtractedData{res.data}, output); err != nil {
-			logger.DebugLog("[writeOutput]: error writing to file %s: %v", output, err)
-			results.addFailure(res.path, fmt.Errorf("writing to file %s: %w", output, err))
+		results.record(res.path, manifest.StatusWriting, nil)
+		if err := writer.Write([]data.ExtractedData{res.data}); err != nil {
+			logger.DebugLog("[writeOutput]: error writing data for %s: %v", res.path, err)
+			results.addWriteFailure(res.path, fmt.Errorf("writing output: %w", err))
 			continue
 		}
 
@@ -45,19 +44,57 @@ func writeOutput(ctx context.Context,
 		results.addWrite(res.path, res.data)
 	}
 
-	logger.DebugLog("[writeOutput]: closing CSV writer")
-	writer.Close()
-	logger.DebugLog("[writeOutput]: CSV writer closed")
+	logger.DebugLog("[writeOutput]: flushing and closing sinks")
+	if err := writer.Flush(); err != nil {
+		errChan <- fmt.Errorf("[writeOutput]: flushing sinks: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		errChan <- fmt.Errorf("[writeOutput]: closing sinks: %w", err)
+	}
+	logger.DebugLog("[writeOutput]: sinks closed")
 }
 
 func (r *writeResult[T]) addWrite(path string, data T) {
 	r.mu.Lock()
 	r.writes[path] = data
 	r.mu.Unlock()
+	r.record(path, manifest.StatusDone, nil)
 }
 
 func (r *writeResult[T]) addFailure(path string, err error) {
 	r.mu.Lock()
 	r.failures[path] = err
 	r.mu.Unlock()
+
+	status := manifest.StatusFailed
+	switch {
+	case errors.Is(err, ErrSkipped):
+		status = manifest.StatusSkipped
+	case errors.Is(err, ErrTimeout):
+		status = manifest.StatusTimeout
+	}
+	r.record(path, status, err)
+}
+
+// addWriteFailure reports an input whose record some sinks may have written
+// before another one failed. It stays journaled as writing, so that resuming
+// retries it without duplicating the record in the sinks that have it.
+func (r *writeResult[T]) addWriteFailure(path string, err error) {
+	r.mu.Lock()
+	r.failures[path] = err
+	r.mu.Unlock()
+	r.record(path, manifest.StatusWriting, err)
+}
+
+func (r *writeResult[T]) record(path string, status manifest.Status, cause error) {
+	if err := r.journal.Record(path, status, cause); err != nil {
+		logger.DebugLog("[writeResult]: error journaling %s: %v", path, err)
+		r.mu.Lock()
+		r.failures["manifest"] = fmt.Errorf("journaling %s: %w", path, err)
+		r.mu.Unlock()
+	}
+}
+
+func (r *writeResult[T]) addSkipped(path string, cause error) {
+	r.addFailure(path, fmt.Errorf("%w: %w", ErrSkipped, cause))
 }
@@ -0,0 +1,27 @@
+package writer
+
+import "fmt"
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatJSON, FormatNDJSON:
+		return f, nil
+	case "":
+		return FormatCSV, nil
+	default:
+		return "", fmt.Errorf("unknown output format: %s", s)
+	}
+}
+
+// Extension returns the file extension, without the dot, used for the format.
+func (f Format) Extension() string {
+	return string(f)
+}
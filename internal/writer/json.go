@@ -0,0 +1,130 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// JSONWriter maintains a single JSON array on disk. Every append rewrites
+// only the closing bracket, so the file is a valid array after each write
+// even if the process is interrupted before Close.
+type JSONWriter[T any] struct {
+	*fileQueue[T]
+	ends map[string]arrayEnd // closing bracket of each file written, only touched by the queue worker
+}
+
+// arrayEnd locates the closing bracket of a JSON array file. size detects
+// changes made by someone else, which require scanning the file again.
+type arrayEnd struct {
+	offset int64
+	empty  bool
+	size   int64
+}
+
+func NewJSONWriter[T any]() *JSONWriter[T] {
+	jw := &JSONWriter[T]{ends: make(map[string]arrayEnd)}
+	jw.fileQueue = newFileQueue(jw.writeToFileSync)
+	return jw
+}
+
+// NewJSONSink returns a JSONWriter bound to outputPath for use as a Sink.
+func NewJSONSink[T any](outputPath string) *JSONWriter[T] {
+	w := NewJSONWriter[T]()
+	w.outputPath = outputPath
+	return w
+}
+
+func (jw *JSONWriter[T]) writeToFileSync(data []T, outputPath string, mode WriteMode) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+
+	flags := os.O_CREATE | os.O_RDWR
+	if mode == ModeReplace {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(outputPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("opening JSON file: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("reading JSON file %s: %w", outputPath, err)
+	}
+	// Scanning the file for every write would be quadratic in its size
+	end, known := jw.ends[outputPath]
+	if !known || end.size != stat.Size() {
+		if end.offset, end.empty, err = findArrayEnd(file); err != nil {
+			return fmt.Errorf("reading JSON file %s: %w", outputPath, err)
+		}
+	}
+	offset, empty := end.offset, end.empty
+	if len(data) == 0 && offset > 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if offset == 0 {
+		buf.WriteString("[")
+	}
+	for _, item := range data {
+		record, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("writing JSON record: %w", err)
+		}
+		if !empty {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+		buf.Write(record)
+		empty = false
+	}
+	buf.WriteString("\n]\n")
+
+	// The new tail is longer than the bracket it overwrites and only
+	// whitespace may follow it, so the file is never cut short
+	if _, err := file.WriteAt(buf.Bytes(), offset); err != nil {
+		return fmt.Errorf("writing JSON file: %w", err)
+	}
+
+	jw.ends[outputPath] = arrayEnd{
+		offset: offset + int64(buf.Len()-len("]\n")),
+		empty:  empty,
+		size:   max(stat.Size(), offset+int64(buf.Len())),
+	}
+	return nil
+}
+
+// findArrayEnd returns the offset of the closing bracket of the array stored
+// in file and whether that array is empty. An empty file yields offset 0.
+func findArrayEnd(file *os.File) (offset int64, empty bool, err error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+	if stat.Size() == 0 {
+		return 0, true, nil
+	}
+
+	content, err := io.ReadAll(io.NewSectionReader(file, 0, stat.Size()))
+	if err != nil {
+		return 0, false, err
+	}
+	trimmed := bytes.TrimRight(content, " \t\r\n")
+	if len(trimmed) == 0 {
+		return 0, true, nil
+	}
+	if trimmed[len(trimmed)-1] != ']' {
+		return 0, false, fmt.Errorf("existing content is not a JSON array")
+	}
+
+	end := len(trimmed) - 1
+	before := bytes.TrimRight(trimmed[:end], " \t\r\n")
+	return int64(end), len(before) > 0 && before[len(before)-1] == '[', nil
+}
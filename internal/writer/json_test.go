@@ -0,0 +1,162 @@
+package writer
+
+import (
+	"bufio"
+	"encoding/json"
+	"ocr-tool/internal/data"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONWriter_AppendKeepsValidArray(t *testing.T) {
+	// Arrange
+	tempDir := t.TempDir()
+	outputPath := filepath.Join(tempDir, "append_test.json")
+	writer := NewJSONWriter[data.ExtractedData]()
+	defer writer.Close()
+
+	data1 := []data.ExtractedData{{Filename: "test1.jpg", Name: "John Doe"}}
+	data2 := []data.ExtractedData{{Filename: "test2.jpg", Name: "Jane Smith"}, {Filename: "test3.jpg"}}
+
+	// Act & Assert - the file must parse after every single write
+	if err := writer.WriteToFile(data1, outputPath); err != nil {
+		t.Fatalf("First write failed: %v", err)
+	}
+	if records := readJSONFile(t, outputPath); len(records) != 1 {
+		t.Errorf("expected 1 record after first write, got %d", len(records))
+	}
+
+	if err := writer.WriteToFile(data2, outputPath); err != nil {
+		t.Fatalf("Second write failed: %v", err)
+	}
+	records := readJSONFile(t, outputPath)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if records[0].Filename != "test1.jpg" || records[2].Filename != "test3.jpg" {
+		t.Errorf("data integrity check failed: %+v", records)
+	}
+}
+
+func TestJSONWriter_AppendAfterExternalChange(t *testing.T) {
+	// Arrange
+	outputPath := filepath.Join(t.TempDir(), "external.json")
+	writer := NewJSONWriter[data.ExtractedData]()
+	defer writer.Close()
+	if err := writer.WriteToFile([]data.ExtractedData{{Filename: "test1.jpg"}}, outputPath); err != nil {
+		t.Fatalf("First write failed: %v", err)
+	}
+	edited := `[{"Filename": "edited.jpg"}, {"Filename": "test1.jpg"}]`
+	if err := os.WriteFile(outputPath, []byte(edited), 0644); err != nil {
+		t.Fatalf("editing file failed: %v", err)
+	}
+
+	// Act
+	err := writer.WriteToFile([]data.ExtractedData{{Filename: "test2.jpg"}}, outputPath)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Second write failed: %v", err)
+	}
+	records := readJSONFile(t, outputPath)
+	if len(records) != 3 || records[2].Filename != "test2.jpg" {
+		t.Errorf("expected the record appended to the edited array, got %+v", records)
+	}
+}
+
+func TestJSONWriter_AppendOverTrailingWhitespace(t *testing.T) {
+	// Arrange: more whitespace after the bracket than a record takes
+	outputPath := filepath.Join(t.TempDir(), "padded.json")
+	padded := `[{"Filename": "edited.jpg"}]` + strings.Repeat(" ", 500) + "\n"
+	if err := os.WriteFile(outputPath, []byte(padded), 0644); err != nil {
+		t.Fatalf("creating file failed: %v", err)
+	}
+	writer := NewJSONWriter[data.ExtractedData]()
+	defer writer.Close()
+
+	// Act
+	err1 := writer.WriteToFile([]data.ExtractedData{{Filename: "test1.jpg"}}, outputPath)
+	err2 := writer.WriteToFile([]data.ExtractedData{{Filename: "test2.jpg"}}, outputPath)
+
+	// Assert
+	if err1 != nil || err2 != nil {
+		t.Fatalf("writes failed: %v, %v", err1, err2)
+	}
+	records := readJSONFile(t, outputPath)
+	if len(records) != 3 || records[1].Filename != "test1.jpg" || records[2].Filename != "test2.jpg" {
+		t.Errorf("expected the records appended in place, got %+v", records)
+	}
+}
+
+func TestJSONWriter_ReplaceMode(t *testing.T) {
+	// Arrange
+	tempDir := t.TempDir()
+	outputPath := filepath.Join(tempDir, "replace_test.json")
+	writer := NewJSONWriter[data.ExtractedData]()
+	defer writer.Close()
+
+	// Act
+	err1 := writer.WriteToFile([]data.ExtractedData{{Filename: "original.jpg"}}, outputPath)
+	err2 := writer.WriteToFile([]data.ExtractedData{{Filename: "replaced.jpg"}}, outputPath, true)
+
+	// Assert
+	if err1 != nil || err2 != nil {
+		t.Fatalf("writes failed: %v, %v", err1, err2)
+	}
+	records := readJSONFile(t, outputPath)
+	if len(records) != 1 || records[0].Filename != "replaced.jpg" {
+		t.Errorf("expected only replaced content, got %+v", records)
+	}
+}
+
+func TestNDJSONWriter_AppendMode(t *testing.T) {
+	// Arrange
+	tempDir := t.TempDir()
+	outputPath := filepath.Join(tempDir, "append_test.ndjson")
+	writer := NewNDJSONWriter[data.ExtractedData]()
+	defer writer.Close()
+
+	// Act
+	err1 := writer.WriteToFile([]data.ExtractedData{{Filename: "test1.jpg"}}, outputPath)
+	err2 := writer.WriteToFile([]data.ExtractedData{{Filename: "test2.jpg"}}, outputPath)
+
+	// Assert
+	if err1 != nil || err2 != nil {
+		t.Fatalf("writes failed: %v, %v", err1, err2)
+	}
+
+	file, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatalf("failed to open NDJSON file: %v", err)
+	}
+	defer file.Close()
+
+	var filenames []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record data.ExtractedData
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		filenames = append(filenames, record.Filename)
+	}
+	if !stringSlicesEqual(filenames, []string{"test1.jpg", "test2.jpg"}) {
+		t.Errorf("expected [test1.jpg test2.jpg], got %v", filenames)
+	}
+}
+
+// Helper functions
+func readJSONFile(t *testing.T, path string) []data.ExtractedData {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read JSON file: %v", err)
+	}
+
+	var records []data.ExtractedData
+	if err := json.Unmarshal(content, &records); err != nil {
+		t.Fatalf("file is not a valid JSON array: %v\n%s", err, content)
+	}
+	return records
+}
@@ -0,0 +1,57 @@
+package writer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// NDJSONWriter writes one JSON document per line, so every record is
+// durable as soon as its write returns.
+type NDJSONWriter[T any] struct {
+	*fileQueue[T]
+}
+
+func NewNDJSONWriter[T any]() *NDJSONWriter[T] {
+	nw := &NDJSONWriter[T]{}
+	nw.fileQueue = newFileQueue(nw.writeToFileSync)
+	return nw
+}
+
+// NewNDJSONSink returns an NDJSONWriter bound to outputPath for use as a Sink.
+func NewNDJSONSink[T any](outputPath string) *NDJSONWriter[T] {
+	w := NewNDJSONWriter[T]()
+	w.outputPath = outputPath
+	return w
+}
+
+func (nw *NDJSONWriter[T]) writeToFileSync(data []T, outputPath string, mode WriteMode) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if mode == ModeReplace {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+	file, err := os.OpenFile(outputPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("opening NDJSON file: %w", err)
+	}
+	defer file.Close()
+
+	buf := bufio.NewWriter(file)
+	encoder := json.NewEncoder(buf)
+	for _, item := range data {
+		if err := encoder.Encode(item); err != nil {
+			return fmt.Errorf("writing NDJSON record: %w", err)
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("flushing NDJSON file: %w", err)
+	}
+
+	return nil
+}
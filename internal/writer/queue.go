@@ -0,0 +1,107 @@
+package writer
+
+import (
+	"fmt"
+	"sync"
+)
+
+type WriteMode int
+
+const (
+	ModeReplace WriteMode = iota
+	ModeAppend
+)
+
+type WriteRequest[T any] struct {
+	Data       []T
+	OutputPath string
+	Mode       WriteMode
+	ResponseCh chan error
+}
+
+type syncWriteFunc[T any] func(data []T, outputPath string, mode WriteMode) error
+
+// fileQueue serialises write requests onto a single goroutine so that
+// concurrent pipeline workers never interleave writes to the same file.
+type fileQueue[T any] struct {
+	queue    chan WriteRequest[T]
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+	write    syncWriteFunc[T]
+
+	// outputPath is the file used by the Sink methods.
+	outputPath string
+}
+
+func newFileQueue[T any](write syncWriteFunc[T]) *fileQueue[T] {
+	fq := &fileQueue[T]{
+		queue:    make(chan WriteRequest[T], 100),
+		shutdown: make(chan struct{}),
+		write:    write,
+	}
+	fq.startWorker()
+	return fq
+}
+
+func (fq *fileQueue[T]) startWorker() {
+	fq.wg.Add(1)
+	go func() {
+		defer fq.wg.Done()
+		for {
+			select {
+			case req := <-fq.queue:
+				err := fq.write(req.Data, req.OutputPath, req.Mode)
+				req.ResponseCh <- err
+			case <-fq.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+func (fq *fileQueue[T]) Close() error {
+	fq.once.Do(func() {
+		close(fq.shutdown)
+		fq.wg.Wait()
+	})
+	return nil
+}
+
+// Write appends data to the output path the writer was bound to.
+func (fq *fileQueue[T]) Write(data []T) error {
+	if fq.outputPath == "" {
+		return fmt.Errorf("writer has no output path")
+	}
+	return fq.WriteToFileWithMode(data, fq.outputPath, ModeAppend)
+}
+
+// Flush is a no-op: every queued write is flushed to disk before its
+// caller is released.
+func (fq *fileQueue[T]) Flush() error {
+	return nil
+}
+
+func (fq *fileQueue[T]) WriteToFile(data []T, outputPath string, overwrite ...bool) error {
+	if len(overwrite) > 0 && overwrite[0] {
+		return fq.WriteToFileWithMode(data, outputPath, ModeReplace)
+	}
+	return fq.WriteToFileWithMode(data, outputPath, ModeAppend)
+}
+
+func (fq *fileQueue[T]) WriteToFileWithMode(data []T, outputPath string, mode WriteMode) error {
+	responseCh := make(chan error, 1)
+	req := WriteRequest[T]{
+		Data:       data,
+		OutputPath: outputPath,
+		Mode:       mode,
+		ResponseCh: responseCh,
+	}
+
+	select {
+	case fq.queue <- req:
+		return <-responseCh
+	case <-fq.shutdown:
+		return fmt.Errorf("writer is shutting down")
+	}
+}
@@ -0,0 +1,50 @@
+package writer
+
+import "errors"
+
+// Sink is a destination for records that is bound to its output when created.
+type Sink[T any] interface {
+	Write(data []T) error
+	Flush() error
+	Close() error
+}
+
+type multiSink[T any] struct {
+	sinks []Sink[T]
+}
+
+// NewMultiSink fans every write out to all of the given sinks. A failing
+// sink does not stop the others from receiving the record.
+func NewMultiSink[T any](sinks ...Sink[T]) Sink[T] {
+	return &multiSink[T]{sinks: sinks}
+}
+
+func (ms *multiSink[T]) Write(data []T) error {
+	var errs []error
+	for _, sink := range ms.sinks {
+		errs = append(errs, sink.Write(data))
+	}
+	return errors.Join(errs...)
+}
+
+func (ms *multiSink[T]) Flush() error {
+	var errs []error
+	for _, sink := range ms.sinks {
+		errs = append(errs, sink.Flush())
+	}
+	return errors.Join(errs...)
+}
+
+func (ms *multiSink[T]) Close() error {
+	var errs []error
+	for _, sink := range ms.sinks {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	_ Sink[any] = (*CSVWriter[any])(nil)
+	_ Sink[any] = (*JSONWriter[any])(nil)
+	_ Sink[any] = (*NDJSONWriter[any])(nil)
+)
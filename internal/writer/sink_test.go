@@ -0,0 +1,48 @@
+package writer
+
+import (
+	"ocr-tool/internal/data"
+	"path/filepath"
+	"testing"
+)
+
+func TestMultiSink_FansOutToAllSinks(t *testing.T) {
+	// Arrange
+	tempDir := t.TempDir()
+	csvPath := filepath.Join(tempDir, "out.csv")
+	jsonPath := filepath.Join(tempDir, "out.json")
+	sink := NewMultiSink[data.ExtractedData](
+		NewCSVSink(csvPath, data.MapCSVRecord, data.GetCSVHeader),
+		NewJSONSink[data.ExtractedData](jsonPath),
+	)
+
+	// Act
+	err1 := sink.Write([]data.ExtractedData{{Filename: "test1.jpg"}})
+	err2 := sink.Write([]data.ExtractedData{{Filename: "test2.jpg"}})
+	errClose := sink.Close()
+
+	// Assert
+	if err1 != nil || err2 != nil || errClose != nil {
+		t.Fatalf("sink operations failed: %v, %v, %v", err1, err2, errClose)
+	}
+	if records := readCSVFile(t, csvPath); len(records) != 3 {
+		t.Errorf("expected 3 CSV records (header + data), got %d", len(records))
+	}
+	if records := readJSONFile(t, jsonPath); len(records) != 2 {
+		t.Errorf("expected 2 JSON records, got %d", len(records))
+	}
+}
+
+func TestMultiSink_UnboundWriterFails(t *testing.T) {
+	// Arrange
+	sink := NewMultiSink[data.ExtractedData](NewNDJSONWriter[data.ExtractedData]())
+	defer sink.Close()
+
+	// Act
+	err := sink.Write([]data.ExtractedData{{Filename: "test.jpg"}})
+
+	// Assert
+	if err == nil {
+		t.Errorf("expected error for writer without output path, got none")
+	}
+}
@@ -2,35 +2,26 @@ package writer
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 )
 
-type WriteMode int
-
-const (
-	ModeReplace WriteMode = iota
-	ModeAppend
-)
+// ErrHeaderMismatch is returned when appending to a CSV file written with
+// other columns, whose rows would no longer line up with its header.
+var ErrHeaderMismatch = errors.New("CSV header mismatch")
 
 type MapperFunc[T any] func(T) []string
 
 type HeaderFunc[T any] func() []string
 
-type WriteRequest[T any] struct {
-	Data       []T
-	OutputPath string
-	Mode       WriteMode
-	ResponseCh chan error
-}
-
 type CSVWriter[T any] struct {
-	queue         chan WriteRequest[T]
-	shutdown      chan struct{}
-	wg            sync.WaitGroup
-	once          sync.Once
+	*fileQueue[T]
 	headerTracker map[string]bool // Track if file has header written
 	mu            sync.RWMutex    // Protect headerTracker map
 	mapper        MapperFunc[T]
@@ -39,61 +30,19 @@ type CSVWriter[T any] struct {
 
 func NewCSVWriter[T any](mapper MapperFunc[T], header HeaderFunc[T]) *CSVWriter[T] {
 	cw := &CSVWriter[T]{
-		queue:         make(chan WriteRequest[T], 100),
-		shutdown:      make(chan struct{}),
 		headerTracker: make(map[string]bool),
 		mapper:        mapper,
 		header:        header,
 	}
-	cw.startWorker()
+	cw.fileQueue = newFileQueue(cw.writeToFileSync)
 	return cw
 }
 
-func (cw *CSVWriter[T]) startWorker() {
-	cw.wg.Add(1)
-	go func() {
-		defer cw.wg.Done()
-		for {
-			select {
-			case req := <-cw.queue:
-				err := cw.writeToFileSync(req.Data, req.OutputPath, req.Mode)
-				req.ResponseCh <- err
-			case <-cw.shutdown:
-				return
-			}
-		}
-	}()
-}
-
-func (cw *CSVWriter[T]) Close() {
-	cw.once.Do(func() {
-		close(cw.shutdown)
-		cw.wg.Wait()
-	})
-}
-
-func (cw *CSVWriter[T]) WriteToFile(data []T, outputPath string, overwrite ...bool) error {
-	if len(overwrite) > 0 && overwrite[0] {
-		return cw.WriteToFileWithMode(data, outputPath, ModeReplace)
-	}
-	return cw.WriteToFileWithMode(data, outputPath, ModeAppend)
-}
-
-func (cw *CSVWriter[T]) WriteToFileWithMode(data []T, outputPath string, mode WriteMode) error {
-	responseCh := make(chan error, 1)
-	req := WriteRequest[T]{
-		Data:       data,
-		OutputPath: outputPath,
-		Mode:       mode,
-		ResponseCh: responseCh,
-	}
-
-	select {
-	case cw.queue <- req:
-		return <-responseCh
-	case <-cw.shutdown:
-		return fmt.Errorf("writer is shutting down")
-	}
+// NewCSVSink returns a CSVWriter bound to outputPath for use as a Sink.
+func NewCSVSink[T any](outputPath string, mapper MapperFunc[T], header HeaderFunc[T]) *CSVWriter[T] {
+	w := NewCSVWriter(mapper, header)
+	w.outputPath = outputPath
+	return w
 }
 
 func (cw *CSVWriter[T]) writeToFileSync(data []T, outputPath string, mode WriteMode) error {
@@ -117,6 +66,13 @@ func (cw *CSVWriter[T]) writeToFileSync(data []T, outputPath string, mode WriteM
 		hasHeader = false
 		cw.mu.Unlock()
 	} else {
+		// Refuse to append rows under the header of another version
+		if !hasHeader {
+			if err := CheckCSVHeader(outputPath, cw.header()); err != nil {
+				return err
+			}
+		}
+
 		// Append mode: open for append or create if doesn't exist
 		file, err = os.OpenFile(outputPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 
@@ -159,3 +115,31 @@ func (cw *CSVWriter[T]) writeToFileSync(data []T, outputPath string, mode WriteM
 
 	return nil
 }
+
+// CheckCSVHeader reports ErrHeaderMismatch when the CSV file at path already
+// starts with another header. A missing or empty file passes.
+func CheckCSVHeader(path string, header []string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening CSV file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	existing, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading CSV header of %s: %w", path, err)
+	}
+	if !slices.Equal(existing, header) {
+		return fmt.Errorf("%w: %s has columns %s, expected %s; move it away or use another output directory",
+			ErrHeaderMismatch, path, strings.Join(existing, ","), strings.Join(header, ","))
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package writer
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"ocr-tool/internal/data"
 	"os"
@@ -39,7 +40,7 @@ func TestCSVWriter_AppendMode(t *testing.T) {
 		},
 	}
 
-	expectedHeader := []string{"Filename", "Name", "Email", "Phone", "Tags", "Text"}
+	expectedHeader := []string{"Filename", "Page", "Rotation", "Variant", "Confidence", "Attempts", "Name", "Email", "Phone", "Tags", "Text"}
 	expectedRecords := 3 // header + 2 data rows
 
 	// Act
@@ -169,6 +170,28 @@ func TestCSVWriter_EmptyData(t *testing.T) {
 	}
 }
 
+func TestCSVWriter_AppendHeaderMismatch(t *testing.T) {
+	// Arrange: a file written by a version with fewer columns
+	outputPath := filepath.Join(t.TempDir(), "old.csv")
+	old := "Filename,Name,Email,Phone,Tags,Text\nold.jpg,,,,,\n"
+	if err := os.WriteFile(outputPath, []byte(old), 0644); err != nil {
+		t.Fatalf("creating file failed: %v", err)
+	}
+	writer := NewCSVWriter(data.MapCSVRecord, data.GetCSVHeader)
+	defer writer.Close()
+
+	// Act
+	err := writer.WriteToFile([]data.ExtractedData{{Filename: "new.jpg"}}, outputPath)
+
+	// Assert
+	if !errors.Is(err, ErrHeaderMismatch) {
+		t.Errorf("expected ErrHeaderMismatch, got %v", err)
+	}
+	if content, _ := os.ReadFile(outputPath); string(content) != old {
+		t.Errorf("expected the file to be left untouched, got %q", content)
+	}
+}
+
 func TestCSVWriter_InvalidPath(t *testing.T) {
 	// Arrange
 	writer := NewCSVWriter(data.MapCSVRecord, data.GetCSVHeader)
@@ -8,11 +8,11 @@ import (
 )
 
 func main() {
-	// This is a deprecated wrapper - use cmd/ocr-tool/main.go instead
+	// This is a deprecated wrapper - use ./cmd/ocr-tool instead
 	fmt.Println("Note: Please use the new CLI tool at cmd/ocr-tool/")
-	fmt.Println("Running: go run cmd/ocr-tool/main.go cmd/ocr-tool/cli.go")
+	fmt.Println("Running: go run ./cmd/ocr-tool")
 
-	cmd := exec.Command("go", append([]string{"run", "cmd/ocr-tool/main.go", "cmd/ocr-tool/cli.go"}, os.Args[1:]...)...)
+	cmd := exec.Command("go", append([]string{"run", "./cmd/ocr-tool"}, os.Args[1:]...)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin